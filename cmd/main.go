@@ -1,8 +1,18 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	"L3_6/internal/logging"
+	"L3_6/internal/recurring"
 	"L3_6/internal/server"
 	"L3_6/internal/storage"
 	"L3_6/models"
@@ -10,29 +20,99 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+// defaultConfigPath is read when neither -config nor CONFIG_PATH is given.
+const defaultConfigPath = "config.yaml"
+
+// configPath picks the config file from the -config flag in args, else the
+// CONFIG_PATH variable, else defaultConfigPath.
+func configPath(args []string) string {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	path := flags.String("config", os.Getenv("CONFIG_PATH"), "config file; .yaml, .yml, .json and .toml are supported (env CONFIG_PATH)")
+	flags.Parse(args)
+	if *path == "" {
+		return defaultConfigPath
+	}
+	return *path
+}
+
+// loadConfig reads the config file at path, in the format its extension
+// names, and applies environment overrides.
 func loadConfig(path string) *models.Config {
 	conf := &models.Config{}
-	if err := cleanenv.ReadConfig(path, conf); err != nil {
-		log.Fatal("Can't read the common config")
-		return nil
+	if err := readConfig(path, conf); err != nil {
+		fatal("can't read the config", "path", path, "err", err)
 	}
 	return conf
 }
 
+// readConfig is cleanenv.ReadConfig, except that JSON is parsed as the YAML
+// subset it is: encoding/json can't read durations such as "15s", and this
+// way the yaml tags name the keys for both.
+func readConfig(path string, conf *models.Config) error {
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		return cleanenv.ReadConfig(path, conf)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := cleanenv.ParseYAML(f, conf); err != nil {
+		return fmt.Errorf("config file parsing error: %w", err)
+	}
+	return cleanenv.ReadEnv(conf)
+}
+
+// fatal logs msg at error level and exits, replacing log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// @title Sales Tracker API
+// @version 1.0
+// @description Records income and expense sales and reports analytics on them.
+// @BasePath /api
+//
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer " followed by a token from POST /api/login.
+//
+// @securityDefinitions.apikey AdminKey
+// @in header
+// @name X-Admin-Key
 func main() {
-	cfg := loadConfig("config.yaml")
+	cfg := loadConfig(configPath(os.Args[1:]))
+	if err := cfg.Validate(); err != nil {
+		fatal("invalid config", "err", err)
+	}
+	slog.SetDefault(logging.New(cfg, os.Stdout))
 
-	db, err := storage.InitDB(cfg)
+	db, schemaVersion, err := storage.InitDB(cfg)
 	if err != nil {
-		log.Fatal(err)
+		fatal("can't initialize the database", "err", err)
 	}
 	defer db.Close()
 
 	st := storage.NewStorage(db)
-	srv := server.NewServer(st)
+	srv := server.NewServer(st, cfg)
+	srv.SetStartupSchemaVersion(schemaVersion)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Server starting on port %s", cfg.Server.Port)
-	if err := srv.Run(cfg.Server.Port); err != nil {
-		log.Fatal(err)
+	go recurring.NewScheduler(st, srv, cfg).Run(ctx)
+
+	scheme := "http"
+	if srv.TLSEnabled() {
+		scheme = "https"
+	}
+	slog.Info("server starting", "port", cfg.Server.Port, "scheme", scheme)
+	if err := srv.Run(ctx, cfg.Server.Port); err != nil {
+		db.Close()
+		fatal("server stopped", "err", err)
 	}
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig_EnvOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "server:\n  port: \"8080\"\ndatabase:\n  host: \"db\"\n  password: \"from-file\"\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DB_PASSWORD", "from-env")
+
+	cfg := loadConfig(path)
+	assert.Equal(t, "from-env", cfg.Database.Password)
+	assert.Equal(t, "db", cfg.Database.Host, "unset variables keep the file value")
+	assert.Equal(t, "8080", cfg.Server.Port)
+}
+
+func TestLoadConfig_Formats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{"server": {"port": "9090", "max_body_bytes": 2048, "read_timeout": "5s"}}`,
+		"config.toml": "[server]\nport = \"9090\"\nmax_body_bytes = 2048\nread_timeout = \"5s\"\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := loadConfig(path)
+			assert.Equal(t, "9090", cfg.Server.Port)
+			assert.Equal(t, int64(2048), cfg.Server.MaxBodyBytes)
+			assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+			assert.Equal(t, 60*time.Second, cfg.Server.IdleTimeout, "missing keys fall back to env-default")
+		})
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	t.Setenv("CONFIG_PATH", "")
+	assert.Equal(t, defaultConfigPath, configPath(nil))
+
+	t.Setenv("CONFIG_PATH", "/etc/sales/config.toml")
+	assert.Equal(t, "/etc/sales/config.toml", configPath(nil))
+	assert.Equal(t, "local.json", configPath([]string{"-config", "local.json"}), "the flag wins over the variable")
+}
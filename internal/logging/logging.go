@@ -0,0 +1,26 @@
+// Package logging builds the application's structured logger.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+
+	"L3_6/models"
+)
+
+// New returns a logger writing to w with the configured level and format.
+// Config.Validate rejects unknown values; an empty level means info and any
+// format other than "text" means JSON.
+func New(cfg *models.Config, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	if strings.EqualFold(cfg.Log.Format, "text") {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"L3_6/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("level filters records", func(t *testing.T) {
+		var buf bytes.Buffer
+		cfg := &models.Config{}
+		cfg.Log.Level = "warn"
+		logger := New(cfg, &buf)
+
+		logger.Info("hidden")
+		logger.Warn("shown", "n", 1)
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, "shown", entry["msg"])
+		assert.Equal(t, "WARN", entry["level"])
+	})
+
+	t.Run("text format", func(t *testing.T) {
+		var buf bytes.Buffer
+		cfg := &models.Config{}
+		cfg.Log.Format = "text"
+		New(cfg, &buf).Info("hello", "key", "value")
+
+		assert.True(t, strings.HasPrefix(buf.String(), "time="))
+		assert.Contains(t, buf.String(), "key=value")
+	})
+
+	t.Run("empty level means info", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(&models.Config{}, &buf)
+		logger.Debug("hidden")
+		assert.Empty(t, buf.String())
+		logger.Info("shown")
+		assert.NotEmpty(t, buf.String())
+	})
+}
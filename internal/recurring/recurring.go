@@ -0,0 +1,82 @@
+package recurring
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"L3_6/models"
+)
+
+// Store turns due recurring rules into sales.
+type Store interface {
+	MaterializeDueRules(now time.Time, loc *time.Location) ([]models.Sale, error)
+}
+
+// Notifier is told about every sale the scheduler creates, so webhook
+// subscribers hear of them like of sales created through the API.
+type Notifier interface {
+	Notify(event string, data any)
+}
+
+// Scheduler periodically materializes due recurring rules. Materialization is
+// idempotent, so a restart or an overlapping instance never duplicates sales.
+type Scheduler struct {
+	store    Store
+	notifier Notifier
+	interval time.Duration
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewScheduler creates a scheduler polling every cfg.Recurring.PollInterval.
+// Calendar arithmetic uses the analytics time zone, falling back to UTC.
+func NewScheduler(store Store, notifier Notifier, cfg *models.Config) *Scheduler {
+	loc := time.UTC
+	if cfg.Analytics.Timezone != "" {
+		if l, err := time.LoadLocation(cfg.Analytics.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	return &Scheduler{
+		store:    store,
+		notifier: notifier,
+		interval: cfg.Recurring.PollInterval,
+		location: loc,
+		now:      time.Now,
+	}
+}
+
+// Run materializes due rules immediately and then on every tick until ctx is
+// done. It returns at once if polling is disabled.
+func (s *Scheduler) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick() {
+	created, err := s.store.MaterializeDueRules(s.now(), s.location)
+	if len(created) > 0 {
+		slog.Info("recurring: created sales", "count", len(created))
+	}
+	for _, sale := range created {
+		s.notifier.Notify("sale.created", sale)
+	}
+	if err != nil {
+		slog.Error("recurring: materialize due rules", "err", err)
+	}
+}
@@ -0,0 +1,83 @@
+package recurring
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingStore struct {
+	calls atomic.Int32
+	due   []models.Sale
+}
+
+func (c *countingStore) MaterializeDueRules(time.Time, *time.Location) ([]models.Sale, error) {
+	c.calls.Add(1)
+	return c.due, nil
+}
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []string
+	sales  []models.Sale
+}
+
+func (r *recordingNotifier) Notify(event string, data any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	r.sales = append(r.sales, data.(models.Sale))
+}
+
+func TestScheduler_Run(t *testing.T) {
+	t.Run("polls until cancelled", func(t *testing.T) {
+		store := &countingStore{}
+		cfg := &models.Config{}
+		cfg.Recurring.PollInterval = 5 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			NewScheduler(store, &recordingNotifier{}, cfg).Run(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return store.calls.Load() >= 3 }, time.Second, time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Run did not return after cancel")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		store := &countingStore{}
+		NewScheduler(store, &recordingNotifier{}, &models.Config{}).Run(context.Background())
+		assert.Zero(t, store.calls.Load())
+	})
+}
+
+func TestScheduler_NotifiesCreatedSales(t *testing.T) {
+	store := &countingStore{due: []models.Sale{{ID: 7, Category: "Rent"}, {ID: 8, Category: "Rent"}}}
+	notifier := &recordingNotifier{}
+	NewScheduler(store, notifier, &models.Config{}).tick()
+
+	assert.Equal(t, []string{"sale.created", "sale.created"}, notifier.events)
+	assert.Equal(t, store.due, notifier.sales)
+}
+
+func TestNewScheduler_Timezone(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Analytics.Timezone = "Europe/Moscow"
+	assert.Equal(t, "Europe/Moscow", NewScheduler(nil, nil, cfg).location.String())
+
+	cfg.Analytics.Timezone = "Nowhere/Special"
+	assert.Equal(t, time.UTC, NewScheduler(nil, nil, cfg).location)
+}
@@ -0,0 +1,706 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"L3_6/internal/storage"
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// getAnalytics summarizes sales in a range, by default the configured
+// analytics.default_range (the current month). Responses carry a weak ETag
+// derived from the request and the range's last modification, so polling
+// clients can revalidate with If-None-Match and get a 304 without the
+// aggregates being recomputed.
+//
+// @Summary Summarize sales in a range
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Param percentiles query string false "Comma-separated percentiles, e.g. 50,90"
+// @Param nocache query bool false "Recompute instead of using cached results"
+// @Param If-None-Match header string false "ETag of a previous response"
+// @Success 200 {object} models.AnalyticsResponse
+// @Success 304 "Analytics unchanged since the given ETag"
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics [get]
+func (s *Server) getAnalytics(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	percentiles, err := parsePercentiles(c.Query("percentiles"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid percentiles: " + err.Error()})
+		return
+	}
+
+	modified, count, err := s.storage.LastModified(from, to)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	etag := analyticsETag(from, to, loc, percentiles, modified, count)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	analytics, err := s.cachedAnalytics(from, to, percentiles, modified, count, c.Query("nocache") == "true")
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	markReliability(analytics, s.cfg.Analytics.MinSampleSize)
+	setAvgPerDay(analytics, from, to, loc)
+
+	c.JSON(http.StatusOK, analytics)
+}
+
+// cachedAnalytics answers from the analytics cache when it is enabled.
+// modified and count are the range's LastModified, so a cached result is
+// only reused while the range is unchanged. With bypass set the cache is not
+// consulted, but the fresh result still replaces the cached one.
+func (s *Server) cachedAnalytics(from, to time.Time, percentiles []float64, modified time.Time, count int64, bypass bool) (*models.AnalyticsResponse, error) {
+	if s.analyticsCache == nil {
+		return s.storage.GetAnalytics(from, to, percentiles...)
+	}
+
+	key := analyticsCacheKey(from, to, percentiles, modified, count)
+	cached, generation, ok := s.analyticsCache.get(key)
+	if ok && !bypass {
+		return cached, nil
+	}
+
+	analytics, err := s.storage.GetAnalytics(from, to, percentiles...)
+	if err != nil {
+		return nil, err
+	}
+	s.analyticsCache.put(key, generation, analytics)
+	return analytics, nil
+}
+
+// analyticsETag builds a weak ETag identifying the analytics of a request:
+// its range, time zone (which avg_per_day depends on) and percentiles plus the
+// range's last modification and count.
+func analyticsETag(from, to time.Time, loc *time.Location, percentiles []float64, modified time.Time, count int64) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d|%d|%s|%v|%d|%d", from.UnixNano(), to.UnixNano(), loc, percentiles, modified.UnixNano(), count)
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag or is "*".
+// Comparison is weak: W/ prefixes are ignored on both sides.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setAvgPerDay fills in the mean number of transactions per calendar day of
+// [from, to] in loc. An empty span leaves it zero.
+func setAvgPerDay(analytics *models.AnalyticsResponse, from, to time.Time, loc *time.Location) {
+	if days := rangeDays(from, to, loc); days > 0 {
+		analytics.AvgPerDay = float64(analytics.Count) / float64(days)
+	}
+}
+
+// rangeDays counts the calendar days in loc from from's day to to's day,
+// inclusive, so a range within one day is 1. It is 0 when to precedes from.
+func rangeDays(from, to time.Time, loc *time.Location) int {
+	fy, fm, fd := from.In(loc).Date()
+	ty, tm, td := to.In(loc).Date()
+	span := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC))
+	return max(int(span.Hours()/24)+1, 0)
+}
+
+// markReliability flags analytics computed from fewer than minSampleSize
+// transactions, where averages and percentiles are easily skewed.
+func markReliability(analytics *models.AnalyticsResponse, minSampleSize int) {
+	analytics.SampleSize = analytics.Count
+	analytics.Reliable = analytics.Count >= minSampleSize
+}
+
+var timeSeriesIntervals = map[string]bool{
+	"day":   true,
+	"week":  true,
+	"month": true,
+}
+
+// @Summary Bucket totals over time
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Param interval query string false "Bucket size" Enums(day, week, month)
+// @Success 200 {array} models.TimeSeriesPoint
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/timeseries [get]
+func (s *Server) getTimeSeries(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "month")
+	if !timeSeriesIntervals[interval] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval: must be day, week or month"})
+		return
+	}
+
+	points, err := s.storage.GetTimeSeries(from, to, interval, loc.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	// Report period starts with the zone's offset, e.g. 2024-01-01T00:00:00+03:00.
+	for i := range points {
+		points[i].Period = points[i].Period.In(loc)
+	}
+
+	c.JSON(http.StatusOK, points)
+}
+
+// getNetWorth reports the cumulative net balance over time: unlike the time
+// series, each period carries the running total of income minus expenses,
+// including everything before the range.
+//
+// @Summary Cumulative net balance over time
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for the periods and date-only bounds; defaults to analytics.timezone"
+// @Param interval query string false "Bucket size" Enums(day, week, month) default(month)
+// @Success 200 {array} models.NetWorthPoint
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/networth [get]
+func (s *Server) getNetWorth(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "month")
+	if !timeSeriesIntervals[interval] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval: must be day, week or month"})
+		return
+	}
+
+	points, err := s.storage.GetNetWorth(from, to, interval, loc.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	for i := range points {
+		points[i].Period = points[i].Period.In(loc)
+	}
+
+	c.JSON(http.StatusOK, points)
+}
+
+// getDistribution totals sales per day of the week or hour of the day in a
+// range, in the requested time zone, to show when money is spent. ?type
+// defaults to expense.
+//
+// @Summary Totals per weekday or hour
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for the buckets and date-only bounds; defaults to analytics.timezone"
+// @Param dimension query string false "Bucket by day of the week (0 is Sunday) or hour of the day" Enums(weekday, hour) default(weekday)
+// @Param type query string false "Sale type" Enums(income, expense) default(expense)
+// @Success 200 {array} models.DistributionBucket
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/distribution [get]
+func (s *Server) getDistribution(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	dimension := c.DefaultQuery("dimension", "weekday")
+	if _, ok := storage.DistributionDimensions[dimension]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dimension: must be weekday or hour"})
+		return
+	}
+	saleType := c.DefaultQuery("type", "expense")
+	if !saleTypes[saleType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+
+	buckets, err := s.storage.GetDistribution(from, to, dimension, saleType, loc.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, buckets)
+}
+
+// parseTimezone reads the optional IANA tz query parameter, defaulting to the
+// configured analytics time zone. On an unknown zone it writes a 400 response
+// and returns ok=false.
+func (s *Server) parseTimezone(c *gin.Context) (*time.Location, bool) {
+	name := c.Query("tz")
+	if name == "" {
+		return s.location, true
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid tz %q", name)})
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseRange reads the from/to query parameters. Each is either an RFC3339
+// timestamp or a YYYY-MM-DD date in loc; a date covers the whole day, so
+// from=2024-01-01&to=2024-01-31 is all of January in that zone. A missing
+// bound is taken from the configured default range (see defaultRange), and is
+// an error if there is none; so is a from after to, defaults included. On
+// invalid input it writes a 400 response and returns ok=false.
+func (s *Server) parseRange(c *gin.Context, loc *time.Location) (from, to time.Time, ok bool) {
+	fromValue, toValue := c.Query("from"), c.Query("to")
+	if fromValue == "" || toValue == "" {
+		if defFrom, defTo, ok := defaultRange(s.cfg.Analytics.DefaultRange, s.now(), loc); ok {
+			from, to = defFrom, defTo
+		}
+	}
+
+	var err error
+	if fromValue != "" || from.IsZero() {
+		if from, err = parseRangeBound(fromValue, loc, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return from, to, false
+		}
+	}
+	if toValue != "" || to.IsZero() {
+		if to, err = parseRangeBound(toValue, loc, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return from, to, false
+		}
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "From must not be after to"})
+		return from, to, false
+	}
+
+	return from, to, true
+}
+
+// defaultRange resolves an analytics.default_range setting to a window in loc
+// as of now: "current_month" is the calendar month containing now, and "Nd"
+// the last N days including today. ok is false for an empty or unknown spec.
+func defaultRange(spec string, now time.Time, loc *time.Location) (from, to time.Time, ok bool) {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	if spec == "current_month" {
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 1, 0).Add(-time.Nanosecond), true
+	}
+	days, found := strings.CutSuffix(spec, "d")
+	if n, err := strconv.Atoi(days); found && err == nil && n > 0 {
+		return today.AddDate(0, 0, 1-n), today.AddDate(0, 0, 1).Add(-time.Nanosecond), true
+	}
+	return from, to, false
+}
+
+// parseRangeBound parses one end of a range. A bare date is the start of that
+// day in loc, or its last instant when endOfDay is set.
+func parseRangeBound(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+// parsePercentiles parses a comma-separated list of percentiles in [0, 100]
+// and returns them as fractions. An empty value yields nil (server defaults).
+func parsePercentiles(value string) ([]float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var percentiles []float64
+	for _, part := range strings.Split(value, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(p) || p < 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %q must be a number between 0 and 100", part)
+		}
+		percentiles = append(percentiles, p/100)
+	}
+	return percentiles, nil
+}
+
+const (
+	defaultTopCategories = 10
+	maxTopCategories     = 100
+)
+
+// getTopCategories lists the categories with the largest totals in a range,
+// for expenses unless ?type=income. ?limit defaults to 10 and is capped at 100.
+//
+// @Summary List the largest categories
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Param type query string false "Sale type" Enums(income, expense) default(expense)
+// @Param limit query int false "Maximum categories, capped at 100" default(10)
+// @Success 200 {array} models.CategoryTotal
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/top-categories [get]
+func (s *Server) getTopCategories(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	saleType := c.DefaultQuery("type", "expense")
+	if saleType != "income" && saleType != "expense" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+
+	limit := defaultTopCategories
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: must be a positive integer"})
+			return
+		}
+		limit = min(n, maxTopCategories)
+	}
+
+	totals, err := s.storage.GetTopCategories(from, to, saleType, limit)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, totals)
+}
+
+// getTagTotals totals sales per tag in a range, for expenses unless
+// ?type=income.
+//
+// @Summary Totals per tag
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Param type query string false "Sale type" Enums(income, expense) default(expense)
+// @Success 200 {array} models.TagTotal
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/tags [get]
+func (s *Server) getTagTotals(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	saleType := c.DefaultQuery("type", "expense")
+	if !saleTypes[saleType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+
+	totals, err := s.storage.GetTagTotals(from, to, saleType)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, totals)
+}
+
+// getPaymentMethodTotals totals sales per payment method in a range, for
+// expenses unless ?type=income. Sales without a payment method are totalled
+// under an empty method.
+//
+// @Summary Totals per payment method
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Param type query string false "Sale type" Enums(income, expense) default(expense)
+// @Success 200 {array} models.PaymentMethodTotal
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/payment-methods [get]
+func (s *Server) getPaymentMethodTotals(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	saleType := c.DefaultQuery("type", "expense")
+	if !saleTypes[saleType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+
+	totals, err := s.storage.GetPaymentMethodTotals(from, to, saleType)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, totals)
+}
+
+// dailyDefaultDays is the span of the daily summary when no range is given.
+const dailyDefaultDays = 90
+
+// getDailyExpenses returns per-day expense totals for a calendar heatmap.
+// from and to are optional and default to the last 90 days including today.
+//
+// @Summary Daily expense totals
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults to 90 days ago"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults to today"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Success 200 {array} models.DailyTotal
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/daily [get]
+func (s *Server) getDailyExpenses(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+
+	from, to := defaultDailyRange(s.now(), loc)
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = parseRangeBound(value, loc, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = parseRangeBound(value, loc, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return
+		}
+	}
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "From must not be after to"})
+		return
+	}
+
+	days, err := s.storage.GetDailyExpenses(from, to, loc.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, days)
+}
+
+// defaultDailyRange spans the 90 calendar days in loc ending with the day
+// containing now.
+func defaultDailyRange(now time.Time, loc *time.Location) (from, to time.Time) {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	return today.AddDate(0, 0, 1-dailyDefaultDays), today.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// @Summary Project the month-end total
+// @Tags analytics
+// @Produce json
+// @Param type query string false "Sale type" Enums(income, expense) default(expense)
+// @Success 200 {object} models.PaceResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/pace [get]
+func (s *Server) getPace(c *gin.Context) {
+	saleType := c.DefaultQuery("type", "expense")
+	if saleType != "income" && saleType != "expense" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+
+	now := s.now().In(s.location)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, s.location)
+
+	toDate, err := s.storage.SumByType(saleType, monthStart, now)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, projectPace(toDate, now))
+}
+
+// projectPace linearly extrapolates a month-to-date total to the end of the
+// month containing now, rounded to cents. The current day counts as elapsed.
+func projectPace(toDate decimal.Decimal, now time.Time) models.PaceResponse {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+	daysElapsed := now.Day()
+
+	return models.PaceResponse{
+		ToDate:      toDate,
+		DaysElapsed: daysElapsed,
+		DaysInMonth: daysInMonth,
+		Projected:   toDate.Mul(decimal.NewFromInt(int64(daysInMonth))).DivRound(decimal.NewFromInt(int64(daysElapsed)), 2),
+	}
+}
+
+// @Summary Longest and current activity streaks
+// @Tags analytics
+// @Produce json
+// @Success 200 {object} models.StreakResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/streak [get]
+func (s *Server) getStreak(c *gin.Context) {
+	days, err := s.storage.GetActiveDays(s.location.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, computeStreaks(days, s.now().In(s.location)))
+}
+
+// getPeriods lists the months containing sales, newest first, for a month
+// picker. Months follow the configured analytics time zone.
+//
+// @Summary List months with sales
+// @Tags sales
+// @Produce json
+// @Success 200 {array} models.Period
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /periods [get]
+func (s *Server) getPeriods(c *gin.Context) {
+	periods, err := s.storage.GetPeriods(s.location.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, periods)
+}
+
+// computeStreaks finds the longest and current runs of consecutive days.
+// days must be distinct, ascending calendar days at midnight UTC; now is
+// interpreted in its own location.
+func computeStreaks(days []time.Time, now time.Time) models.StreakResponse {
+	var streak models.StreakResponse
+	if len(days) == 0 {
+		return streak
+	}
+
+	run := 1
+	streak.Longest = 1
+	for i := 1; i < len(days); i++ {
+		if days[i].Equal(days[i-1].AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > streak.Longest {
+			streak.Longest = run
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	last := days[len(days)-1]
+	if last.Equal(today) || last.Equal(today.AddDate(0, 0, -1)) {
+		streak.Current = run
+	}
+
+	return streak
+}
@@ -0,0 +1,538 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectPace(t *testing.T) {
+	t.Run("mid-month projection", func(t *testing.T) {
+		now := time.Date(2024, 4, 15, 12, 0, 0, 0, time.UTC)
+
+		pace := projectPace(decimal.RequireFromString("450.00"), now)
+		assert.Equal(t, "450", pace.ToDate.String())
+		assert.Equal(t, 15, pace.DaysElapsed)
+		assert.Equal(t, 30, pace.DaysInMonth)
+		assert.Equal(t, "900", pace.Projected.String())
+	})
+
+	t.Run("leap-year february", func(t *testing.T) {
+		now := time.Date(2024, 2, 10, 8, 0, 0, 0, time.UTC)
+
+		pace := projectPace(decimal.NewFromInt(100), now)
+		assert.Equal(t, 29, pace.DaysInMonth)
+		assert.Equal(t, "290", pace.Projected.String())
+	})
+
+	t.Run("uses the clock's timezone", func(t *testing.T) {
+		loc, err := time.LoadLocation("Asia/Tokyo")
+		assert.NoError(t, err)
+		// 31 Jan 20:00 UTC is already 1 Feb in Tokyo.
+		now := time.Date(2024, 1, 31, 20, 0, 0, 0, time.UTC).In(loc)
+
+		pace := projectPace(decimal.NewFromInt(10), now)
+		assert.Equal(t, 1, pace.DaysElapsed)
+		assert.Equal(t, 29, pace.DaysInMonth)
+		assert.Equal(t, "290", pace.Projected.String())
+	})
+
+	t.Run("rounds to cents", func(t *testing.T) {
+		now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+
+		pace := projectPace(decimal.NewFromInt(10), now)
+		assert.Equal(t, "103.33", pace.Projected.String())
+	})
+}
+
+func TestParsePercentiles(t *testing.T) {
+	percentiles, err := parsePercentiles("")
+	assert.NoError(t, err)
+	assert.Nil(t, percentiles)
+
+	percentiles, err = parsePercentiles("90, 95,99.9")
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{0.9, 0.95, 0.999}, percentiles, 1e-12)
+
+	for _, value := range []string{"101", "-1", "p95", "90,", "NaN"} {
+		_, err := parsePercentiles(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestGetTimeSeries_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	for name, query := range map[string]string{
+		"unknown interval": "from=2024-01-01T00:00:00Z&to=2024-12-31T00:00:00Z&interval=year",
+		"missing from":     "to=2024-12-31T00:00:00Z&interval=month",
+		"unknown tz":       "from=2024-01-01&to=2024-12-31&tz=Mars/Olympus",
+		"from after to":    "from=2024-12-31&to=2024-01-01",
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/analytics/timeseries?"+query, nil)
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestParseRangeBound(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+
+	from, err := parseRangeBound("2024-01-01", moscow, false)
+	assert.NoError(t, err)
+	assert.True(t, from.Equal(time.Date(2023, 12, 31, 21, 0, 0, 0, time.UTC)))
+
+	to, err := parseRangeBound("2024-01-31", moscow, true)
+	assert.NoError(t, err)
+	assert.True(t, to.Equal(time.Date(2024, 1, 31, 20, 59, 59, 999999999, time.UTC)))
+
+	// Explicit offsets win over the requested zone.
+	exact, err := parseRangeBound("2024-01-01T00:00:00Z", moscow, true)
+	assert.NoError(t, err)
+	assert.True(t, exact.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	_, err = parseRangeBound("01/02/2024", moscow, false)
+	assert.Error(t, err)
+}
+
+func TestDefaultRange(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	// 31 Jan 22:00 UTC is already 1 Feb in Moscow.
+	now := time.Date(2024, 1, 31, 22, 0, 0, 0, time.UTC)
+
+	from, to, ok := defaultRange("current_month", now, moscow)
+	require.True(t, ok)
+	assert.True(t, from.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, moscow)))
+	assert.True(t, to.Equal(time.Date(2024, 2, 29, 23, 59, 59, 999999999, moscow)))
+
+	from, to, ok = defaultRange("30d", now, moscow)
+	require.True(t, ok)
+	assert.True(t, from.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, moscow)))
+	assert.True(t, to.Equal(time.Date(2024, 2, 1, 23, 59, 59, 999999999, moscow)))
+
+	for _, spec := range []string{"", "0d", "month", "d"} {
+		_, _, ok := defaultRange(spec, now, moscow)
+		assert.False(t, ok, spec)
+	}
+}
+
+func TestGetAnalytics_DefaultRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotFrom, gotTo time.Time
+	store := &mockStore{
+		lastModified: func(_, _ time.Time) (time.Time, int64, error) { return time.Time{}, 0, nil },
+		getAnalytics: func(from, to time.Time, _ ...float64) (*models.AnalyticsResponse, error) {
+			gotFrom, gotTo = from, to
+			return &models.AnalyticsResponse{}, nil
+		},
+	}
+	cfg := &models.Config{}
+	cfg.Analytics.DefaultRange = "current_month"
+	srv := NewServer(store, cfg)
+	srv.now = func() time.Time { return time.Date(2024, 4, 15, 12, 0, 0, 0, time.UTC) }
+
+	w := serve(srv, http.MethodGet, "/api/analytics", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), gotFrom)
+	assert.Equal(t, time.Date(2024, 4, 30, 23, 59, 59, 999999999, time.UTC), gotTo)
+
+	// An explicit bound overrides its half of the default.
+	w = serve(srv, http.MethodGet, "/api/analytics?from=2024-03-20", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), gotFrom)
+	assert.Equal(t, time.Date(2024, 4, 30, 23, 59, 59, 999999999, time.UTC), gotTo)
+
+	// A bound past the other half of the default makes an empty range.
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/analytics?from=2024-05-01", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/analytics?to=2024-03-31", "").Code)
+
+	// Without a configured default both bounds are required.
+	cfg.Analytics.DefaultRange = ""
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/analytics", "").Code)
+}
+
+func TestComputeStreaks(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	// Four days across the year boundary, a gap, then a three-day run across
+	// a month boundary ending yesterday.
+	days := []time.Time{
+		day(2023, 12, 30), day(2023, 12, 31), day(2024, 1, 1), day(2024, 1, 2),
+		day(2024, 1, 5),
+		day(2024, 2, 28), day(2024, 2, 29), day(2024, 3, 1),
+	}
+
+	t.Run("longest and current", func(t *testing.T) {
+		now := time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC)
+		streak := computeStreaks(days, now)
+		assert.Equal(t, 4, streak.Longest)
+		assert.Equal(t, 3, streak.Current)
+	})
+
+	t.Run("current run broken by a missed day", func(t *testing.T) {
+		now := time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC)
+		streak := computeStreaks(days, now)
+		assert.Equal(t, 4, streak.Longest)
+		assert.Equal(t, 0, streak.Current)
+	})
+
+	t.Run("today is evaluated in the clock's timezone", func(t *testing.T) {
+		loc, err := time.LoadLocation("Asia/Tokyo")
+		assert.NoError(t, err)
+		// 3 Mar 17:00 UTC is already 4 Mar in Tokyo, two days after the last run.
+		now := time.Date(2024, 3, 3, 17, 0, 0, 0, time.UTC).In(loc)
+		assert.Equal(t, 0, computeStreaks(days, now).Current)
+	})
+
+	t.Run("no activity", func(t *testing.T) {
+		assert.Equal(t, models.StreakResponse{}, computeStreaks(nil, time.Now()))
+	})
+}
+
+func TestRangeDays(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	from, err := parseRangeBound("2024-01-01", moscow, false)
+	require.NoError(t, err)
+	to, err := parseRangeBound("2024-01-31", moscow, true)
+	require.NoError(t, err)
+	assert.Equal(t, 31, rangeDays(from, to, moscow))
+	assert.Equal(t, 1, rangeDays(from, from.Add(time.Hour), moscow))
+
+	// Days are counted in the requested zone: these four hours are all on
+	// March 31 in Moscow but straddle midnight in UTC.
+	start := time.Date(2024, 3, 30, 21, 0, 0, 0, time.UTC)
+	assert.Equal(t, 1, rangeDays(start, start.Add(4*time.Hour), moscow))
+	assert.Equal(t, 2, rangeDays(start, start.Add(4*time.Hour), time.UTC))
+
+	assert.Equal(t, 0, rangeDays(to, from, moscow))
+}
+
+func TestGetAnalytics_AvgPerDay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(&mockStore{
+		lastModified: func(_, _ time.Time) (time.Time, int64, error) { return time.Time{}, 0, nil },
+		getAnalytics: func(_, _ time.Time, _ ...float64) (*models.AnalyticsResponse, error) {
+			return &models.AnalyticsResponse{Count: 45}, nil
+		},
+	}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/analytics?from=2024-04-01&to=2024-04-30", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var analytics models.AnalyticsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &analytics))
+	assert.Equal(t, 1.5, analytics.AvgPerDay)
+}
+
+func TestMarkReliability(t *testing.T) {
+	below := &models.AnalyticsResponse{Count: 4}
+	markReliability(below, 5)
+	assert.Equal(t, 4, below.SampleSize)
+	assert.False(t, below.Reliable)
+
+	atThreshold := &models.AnalyticsResponse{Count: 5}
+	markReliability(atThreshold, 5)
+	assert.True(t, atThreshold.Reliable)
+
+	above := &models.AnalyticsResponse{Count: 120}
+	markReliability(above, 5)
+	assert.Equal(t, 120, above.SampleSize)
+	assert.True(t, above.Reliable)
+}
+
+func TestLimitAnalytics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Analytics.MaxConcurrent = 1
+	srv := NewServer(nil, cfg)
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/analytics/timeseries?from=bad", nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Occupy the only slot as an in-flight query would.
+	srv.analyticsSlots <- struct{}{}
+	w := get()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Once the slot frees up the request reaches the handler again.
+	<-srv.analyticsSlots
+	assert.Equal(t, http.StatusBadRequest, get().Code)
+	assert.Empty(t, srv.analyticsSlots)
+}
+
+func TestGetTopCategories(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotType string
+	var gotLimit int
+	store := &mockStore{getTopCategories: func(_, _ time.Time, saleType string, limit int) ([]models.CategoryTotal, error) {
+		gotType, gotLimit = saleType, limit
+		return []models.CategoryTotal{}, nil
+	}}
+	srv := NewServer(store, &models.Config{})
+	get := func(query string) int {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/analytics/top-categories?from=2024-01-01&to=2024-01-31"+query, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get(""))
+	assert.Equal(t, "expense", gotType)
+	assert.Equal(t, 10, gotLimit)
+
+	assert.Equal(t, http.StatusOK, get("&limit=500&type=income"))
+	assert.Equal(t, "income", gotType)
+	assert.Equal(t, 100, gotLimit)
+
+	for _, query := range []string{"&limit=0", "&limit=ten", "&type=transfer"} {
+		assert.Equal(t, http.StatusBadRequest, get(query), query)
+	}
+}
+
+func TestGetTagTotals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotType string
+	srv := NewServer(&mockStore{getTagTotals: func(_, _ time.Time, saleType string) ([]models.TagTotal, error) {
+		gotType = saleType
+		return []models.TagTotal{{Tag: "travel", Total: decimal.RequireFromString("120.00"), Count: 2}}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/analytics/tags?from=2024-01-01&to=2024-01-31", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "expense", gotType, "income and expenses are never added together")
+	assert.JSONEq(t, `[{"tag":"travel","total":"120","count":2}]`, w.Body.String())
+
+	assert.Equal(t, http.StatusOK, serve(srv, http.MethodGet, "/api/analytics/tags?from=2024-01-01&to=2024-01-31&type=income", "").Code)
+	assert.Equal(t, "income", gotType)
+
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/analytics/tags?from=2024-01-01&to=2024-01-31&type=transfer", "").Code)
+}
+
+func TestGetPeriods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotTZ string
+	srv := NewServer(&mockStore{getPeriods: func(tz string) ([]models.Period, error) {
+		gotTZ = tz
+		return []models.Period{{Month: "2024-03", Count: 2, Sum: decimal.RequireFromString("40.00")}}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/periods", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "UTC", gotTZ)
+	assert.JSONEq(t, `[{"month":"2024-03","count":2,"sum":"40"}]`, w.Body.String())
+}
+
+func TestGetAnalytics_ETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	computed := 0
+	modified := time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{
+		lastModified: func(_, _ time.Time) (time.Time, int64, error) { return modified, 3, nil },
+		getAnalytics: func(_, _ time.Time, _ ...float64) (*models.AnalyticsResponse, error) {
+			computed++
+			return &models.AnalyticsResponse{}, nil
+		},
+	}
+	srv := NewServer(store, &models.Config{})
+	get := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/analytics?from=2024-01-01&to=2024-01-31"+query, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+	assert.Equal(t, 1, computed)
+
+	w = get("", `"other", `+etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, 1, computed, "a 304 skips the aggregates")
+
+	w = get("&percentiles=75", etag)
+	assert.Equal(t, http.StatusOK, w.Code, "different parameters make a different ETag")
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	modified = modified.Add(time.Second)
+	w = get("", etag)
+	assert.Equal(t, http.StatusOK, w.Code, "a newer modification invalidates the ETag")
+	assert.Equal(t, 3, computed)
+
+	// The same instants span a different number of days in another zone, so
+	// avg_per_day differs and the ETag must too.
+	getInstants := func(tz, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/analytics?from=2024-01-01T00:00:00Z&to=2024-01-01T20:00:00Z&tz="+tz, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+	w = getInstants("UTC", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = getInstants("Asia/Tokyo", w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, w.Code, "a different tz makes a different ETag")
+}
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`W/"abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`"abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`"x", W/"abc"`, `W/"abc"`))
+	assert.True(t, etagMatches(`*`, `W/"abc"`))
+	assert.False(t, etagMatches(``, `W/"abc"`))
+	assert.False(t, etagMatches(`W/"abd"`, `W/"abc"`))
+}
+
+func TestDefaultDailyRange(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	// 22:00 UTC on 31 March is already 1 April in Moscow.
+	now := time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC)
+
+	from, to := defaultDailyRange(now, moscow)
+	assert.True(t, from.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, moscow)))
+	assert.True(t, to.Equal(time.Date(2024, 4, 1, 23, 59, 59, 999999999, moscow)))
+}
+
+func TestGetDailyExpenses_ConfiguredTimezone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotFrom time.Time
+	var gotTZ string
+	cfg := &models.Config{}
+	cfg.Analytics.Timezone = "Asia/Tokyo"
+	srv := NewServer(&mockStore{getDaily: func(from, _ time.Time, tz string) ([]models.DailyTotal, error) {
+		gotFrom, gotTZ = from, tz
+		return nil, nil
+	}}, cfg)
+	srv.now = func() time.Time { return time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC) }
+
+	require.Equal(t, http.StatusOK, serve(srv, http.MethodGet, "/api/analytics/daily", "").Code)
+	assert.Equal(t, "Asia/Tokyo", gotTZ, "without ?tz the configured zone applies")
+	tokyo := srv.location
+	assert.True(t, gotFrom.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, tokyo)), "the default range ends on today in Tokyo")
+
+	require.Equal(t, http.StatusOK, serve(srv, http.MethodGet, "/api/analytics/daily?from=2024-01-01&to=2024-01-31&tz=UTC", "").Code)
+	assert.Equal(t, "UTC", gotTZ)
+	assert.True(t, gotFrom.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestGetDistribution(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotDimension, gotType, gotTZ string
+	var gotFrom time.Time
+	srv := NewServer(&mockStore{getDistribution: func(from, _ time.Time, dimension, saleType, tz string) ([]models.DistributionBucket, error) {
+		gotFrom, gotDimension, gotType, gotTZ = from, dimension, saleType, tz
+		return []models.DistributionBucket{{Bucket: 0, Sum: decimal.RequireFromString("12.50"), Count: 1}}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/analytics/distribution?from=2024-01-01&to=2024-01-31", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"bucket":0,"sum":"12.5","count":1}]`, w.Body.String())
+	assert.Equal(t, "weekday", gotDimension)
+	assert.Equal(t, "expense", gotType)
+	assert.Equal(t, "UTC", gotTZ)
+
+	w = serve(srv, http.MethodGet, "/api/analytics/distribution?from=2024-01-01&to=2024-01-31&dimension=hour&type=income&tz=Asia/Tokyo", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hour", gotDimension)
+	assert.Equal(t, "income", gotType)
+	assert.Equal(t, "Asia/Tokyo", gotTZ)
+	assert.True(t, gotFrom.Equal(time.Date(2023, 12, 31, 15, 0, 0, 0, time.UTC)), "date-only bounds are read in tz")
+
+	for _, query := range []string{"&dimension=month", "&type=transfer", "&tz=Mars/Olympus"} {
+		w := serve(srv, http.MethodGet, "/api/analytics/distribution?from=2024-01-01&to=2024-01-31"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetNetWorth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotInterval, gotTZ string
+	srv := NewServer(&mockStore{getNetWorth: func(_, _ time.Time, interval, tz string) ([]models.NetWorthPoint, error) {
+		gotInterval, gotTZ = interval, tz
+		return []models.NetWorthPoint{
+			{Period: time.Date(2023, 12, 31, 15, 0, 0, 0, time.UTC), Net: decimal.RequireFromString("100"), Cumulative: decimal.RequireFromString("250")},
+			{Period: time.Date(2024, 1, 31, 15, 0, 0, 0, time.UTC), Net: decimal.RequireFromString("-40"), Cumulative: decimal.RequireFromString("210")},
+		}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/analytics/networth?from=2024-01-01&to=2024-02-29&tz=Asia/Tokyo", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"period":"2024-01-01T00:00:00+09:00","net":"100","cumulative":"250"},
+		{"period":"2024-02-01T00:00:00+09:00","net":"-40","cumulative":"210"}
+	]`, w.Body.String())
+	assert.Equal(t, "month", gotInterval)
+	assert.Equal(t, "Asia/Tokyo", gotTZ)
+
+	w = serve(srv, http.MethodGet, "/api/analytics/networth?from=2024-01-01&to=2024-02-29&interval=week", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "week", gotInterval)
+
+	for _, query := range []string{"&interval=year", "&tz=Mars/Olympus"} {
+		w := serve(srv, http.MethodGet, "/api/analytics/networth?from=2024-01-01&to=2024-02-29"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetPaymentMethodTotals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotType string
+	srv := NewServer(&mockStore{getMethodTotals: func(_, _ time.Time, saleType string) ([]models.PaymentMethodTotal, error) {
+		gotType = saleType
+		return []models.PaymentMethodTotal{
+			{Method: "card", Total: decimal.RequireFromString("120.5"), Count: 3},
+			{Method: "", Total: decimal.RequireFromString("20"), Count: 1},
+		}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/analytics/payment-methods?from=2024-01-01&to=2024-01-31", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"method":"card","total":"120.5","count":3},{"method":"","total":"20","count":1}]`, w.Body.String())
+	assert.Equal(t, "expense", gotType)
+
+	w = serve(srv, http.MethodGet, "/api/analytics/payment-methods?from=2024-01-01&to=2024-01-31&type=income", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "income", gotType)
+
+	w = serve(srv, http.MethodGet, "/api/analytics/payment-methods?from=2024-01-01&to=2024-01-31&type=transfer", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"L3_6/internal/storage"
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attachmentTypes are the content types accepted for attachments. The type is
+// sniffed from the file's contents; the client's claim is ignored.
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// multipartOverhead is the allowance for multipart framing on top of
+// MaxAttachmentBytes when limiting the whole request body.
+const multipartOverhead = 64 << 10
+
+// attachmentBodyLimit is the request body limit for an upload of at most limit
+// bytes; zero means unlimited.
+func attachmentBodyLimit(limit int64) int64 {
+	if limit <= 0 {
+		return 0
+	}
+	return limit + multipartOverhead
+}
+
+// @Summary Upload an attachment
+// @Description Attaches a receipt or other file to a sale. JPEG, PNG, GIF, WebP and PDF files are accepted.
+// @Tags sales
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Sale ID"
+// @Param file formData file true "File"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 413 {object} errorResponse
+// @Failure 415 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id}/attachments [post]
+func (s *Server) uploadAttachment(c *gin.Context) {
+	saleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	limit := s.cfg.Server.MaxAttachmentBytes
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload exceeds %d bytes", limit)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file in form field \"file\""})
+		return
+	}
+	if limit > 0 && fileHeader.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload exceeds %d bytes", limit)})
+		return
+	}
+	if fileHeader.Size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is empty"})
+		return
+	}
+
+	filename, ok := attachmentFilename(fileHeader.Filename)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filename"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !attachmentTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("Unsupported file type %q", contentType)})
+		return
+	}
+
+	attachment := models.Attachment{SaleID: saleID, Filename: filename, ContentType: contentType, Data: data}
+	if err := s.storage.CreateAttachment(&attachment); err != nil {
+		if errors.Is(err, storage.ErrSaleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+			return
+		}
+		respondStorageError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// attachmentFilename reduces a client-supplied filename to its base name,
+// dropping any directory part. It reports false if nothing usable is left or
+// the name is too long to store.
+func attachmentFilename(name string) (string, bool) {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "" || name == "." || name == "/" || utf8.RuneCountInString(name) > 255 {
+		return "", false
+	}
+	return name, true
+}
+
+// @Summary Download an attachment
+// @Tags sales
+// @Produce application/octet-stream
+// @Param id path int true "Sale ID"
+// @Param aid path int true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id}/attachments/{aid} [get]
+func (s *Server) getAttachment(c *gin.Context) {
+	saleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("aid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachment, err := s.storage.GetAttachment(saleID, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrAttachmentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, attachment.ContentType, attachment.Data)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"L3_6/internal/storage"
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG file for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestUploadAttachment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var stored *models.Attachment
+	store := &mockStore{createAttachment: func(a *models.Attachment) error {
+		if a.SaleID == 404 {
+			return fmt.Errorf("storage.CreateAttachment: %w", storage.ErrSaleNotFound)
+		}
+		a.ID, a.Size = 1, int64(len(a.Data))
+		stored = a
+		return nil
+	}}
+	cfg := &models.Config{}
+	cfg.Server.MaxAttachmentBytes = 64
+	srv := NewServer(store, cfg)
+
+	upload := func(saleID int, filename string, content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("file", filename)
+		require.NoError(t, err)
+		_, err = part.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/items/%d/attachments", saleID), &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("stores a receipt", func(t *testing.T) {
+		w := upload(7, `C:\scans\receipt.png`, pngHeader)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var got models.Attachment
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, 7, got.SaleID)
+		assert.Equal(t, "receipt.png", got.Filename, "directories are stripped")
+		assert.Equal(t, "image/png", got.ContentType)
+		assert.EqualValues(t, len(pngHeader), got.Size)
+		assert.NotContains(t, w.Body.String(), "data")
+		assert.Equal(t, pngHeader, stored.Data)
+	})
+
+	t.Run("sniffs the content type", func(t *testing.T) {
+		w := upload(7, "receipt.png", []byte("#!/bin/sh\necho hi\n"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		w := upload(7, "receipt.png", append(pngHeader, make([]byte, 64)...))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("missing sale", func(t *testing.T) {
+		w := upload(404, "receipt.png", pngHeader)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		w := serve(srv, http.MethodPost, "/api/items/7/attachments", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetAttachment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := &mockStore{getAttachment: func(saleID, id int) (*models.Attachment, error) {
+		if saleID != 7 || id != 1 {
+			return nil, fmt.Errorf("storage.GetAttachment: %w", storage.ErrAttachmentNotFound)
+		}
+		return &models.Attachment{ID: 1, SaleID: 7, Filename: "receipt.png", ContentType: "image/png", Data: pngHeader}, nil
+	}}
+	srv := NewServer(store, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items/7/attachments/1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=receipt.png`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, pngHeader, w.Body.Bytes())
+
+	assert.Equal(t, http.StatusNotFound, serve(srv, http.MethodGet, "/api/items/8/attachments/1", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/items/7/attachments/x", "").Code)
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"receipt.pdf":         "receipt.pdf",
+		"../../etc/passwd":    "passwd",
+		`C:\Users\me\a b.jpg`: "a b.jpg",
+	} {
+		got, ok := attachmentFilename(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "  ", "/", `\`} {
+		_, ok := attachmentFilename(in)
+		assert.False(t, ok, in)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type loginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// @Summary Get a bearer token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body loginRequest true "Credentials"
+// @Success 200 {object} loginResponse
+// @Failure 400 {object} errorResponse
+// @Failure 401 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Router /login [post]
+func (s *Server) login(c *gin.Context) {
+	auth := s.cfg.Auth
+	if auth.JWTSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	userOK := subtle.ConstantTimeCompare([]byte(req.Username), []byte(auth.Username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(req.Password), []byte(auth.Password)) == 1
+	if auth.Username == "" || !userOK || !passOK {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	ttl := auth.TokenTTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	now := s.now()
+	expiresAt := now.Add(ttl)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   req.Username,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}).SignedString([]byte(auth.JWTSecret))
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse{Token: token, ExpiresAt: expiresAt.UTC()})
+}
+
+// requireAuth rejects API requests without a valid bearer token. It lets
+// everything through when no JWT secret is configured.
+func (s *Server) requireAuth(c *gin.Context) {
+	secret := s.cfg.Auth.JWTSecret
+	if secret == "" {
+		c.Next()
+		return
+	}
+
+	raw, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || raw == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+		return
+	}
+
+	_, err := jwt.ParseWithClaims(raw, &jwt.RegisteredClaims{}, func(*jwt.Token) (any, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(s.now), jwt.WithExpirationRequired())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	c.Next()
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &models.Config{}
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Auth.Username = "owner"
+	cfg.Auth.Password = "hunter2"
+	srv := NewServer(nil, cfg)
+
+	serve := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("wrong credentials", func(t *testing.T) {
+		w := serve(http.MethodPost, "/api/login", `{"username":"owner","password":"nope"}`, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/analytics/timeseries", "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/analytics/timeseries", "", "not-a-jwt")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("issued token grants access", func(t *testing.T) {
+		w := serve(http.MethodPost, "/api/login", `{"username":"owner","password":"hunter2"}`, "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Token string `json:"token"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotEmpty(t, resp.Token)
+
+		// Past auth, the handler rejects the missing range.
+		w = serve(http.MethodGet, "/api/analytics/timeseries", "", resp.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("open when no secret is configured", func(t *testing.T) {
+		open := NewServer(nil, &models.Config{})
+		req := httptest.NewRequest(http.MethodGet, "/api/analytics/timeseries", nil)
+		w := httptest.NewRecorder()
+		open.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
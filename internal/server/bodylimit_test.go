@@ -0,0 +1,39 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	small := `{"type":"expense","amount":"5","date":"2024-01-10T10:00:00Z","category":"Food"}`
+	large := `{"type":"expense","amount":"5","date":"2024-01-10T10:00:00Z","category":"` + strings.Repeat("x", 100) + `"}`
+	cfg := &models.Config{}
+	cfg.Server.MaxBodyBytes = int64(len(small))
+	srv := NewServer(&mockStore{createSale: func(*models.Sale) error { return nil }}, cfg)
+
+	post := func(body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/items", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.ContentLength = contentLength
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, post(strings.NewReader(small), int64(len(small))).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(strings.NewReader(large), int64(len(large))).Code,
+		"a declared length over the limit is rejected up front")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(io.MultiReader(strings.NewReader(large)), -1).Code,
+		"a body of unknown length is cut off at the limit")
+}
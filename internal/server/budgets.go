@@ -0,0 +1,240 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"L3_6/internal/storage"
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// budgetMonthLayout is the YYYY-MM format budgets use for months.
+const budgetMonthLayout = "2006-01"
+
+type budgetRequest struct {
+	Category string          `json:"category" binding:"required"`
+	Month    string          `json:"month" binding:"required"`
+	Limit    decimal.Decimal `json:"limit"`
+}
+
+// bindBudget reads and validates a budget from the body. On invalid input it
+// writes a 400 response and returns ok=false.
+func bindBudget(c *gin.Context) (models.Budget, bool) {
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return models.Budget{}, false
+	}
+
+	budget := models.Budget{
+		Category: strings.TrimSpace(req.Category),
+		Month:    req.Month,
+		Limit:    req.Limit,
+	}
+	switch {
+	case budget.Category == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category is required"})
+		return budget, false
+	case len(budget.Category) > 255:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category must be at most 255 characters"})
+		return budget, false
+	case !validBudgetMonth(budget.Month):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month: must be YYYY-MM"})
+		return budget, false
+	case !budget.Limit.IsPositive():
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be positive"})
+		return budget, false
+	case validateAmountPrecision(budget.Limit) != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Limit must be at most %s with two decimal places", maxAmount)})
+		return budget, false
+	}
+	return budget, true
+}
+
+func validBudgetMonth(month string) bool {
+	_, err := time.Parse(budgetMonthLayout, month)
+	return err == nil
+}
+
+// listBudgets returns all budgets, or with ?month=YYYY-MM those for one month.
+//
+// @Summary List budgets
+// @Tags budgets
+// @Produce json
+// @Param month query string false "Month (YYYY-MM)"
+// @Success 200 {array} models.Budget
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /budgets [get]
+func (s *Server) listBudgets(c *gin.Context) {
+	month := c.Query("month")
+	if month != "" && !validBudgetMonth(month) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month: must be YYYY-MM"})
+		return
+	}
+
+	budgets, err := s.storage.ListBudgets(month)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+// @Summary Get a budget
+// @Tags budgets
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} models.Budget
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /budgets/{id} [get]
+func (s *Server) getBudget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	budget, err := s.storage.GetBudget(id)
+	if err != nil {
+		respondBudgetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// @Summary Create a budget
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param budget body budgetRequest true "Budget"
+// @Success 201 {object} models.Budget
+// @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /budgets [post]
+func (s *Server) createBudget(c *gin.Context) {
+	budget, ok := bindBudget(c)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.CreateBudget(&budget); err != nil {
+		respondBudgetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, budget)
+}
+
+// @Summary Replace a budget
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param budget body budgetRequest true "Budget"
+// @Success 200 {object} models.Budget
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /budgets/{id} [put]
+func (s *Server) updateBudget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	budget, ok := bindBudget(c)
+	if !ok {
+		return
+	}
+	budget.ID = id
+
+	if err := s.storage.UpdateBudget(&budget); err != nil {
+		respondBudgetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// @Summary Delete a budget
+// @Tags budgets
+// @Produce json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /budgets/{id} [delete]
+func (s *Server) deleteBudget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := s.storage.DeleteBudget(id); err != nil {
+		respondBudgetError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getBudgetStatus reports spending against each budget for ?month=YYYY-MM,
+// defaulting to the current month. Months follow the analytics time zone.
+//
+// @Summary Report spending against budgets
+// @Tags budgets
+// @Produce json
+// @Param month query string false "Month (YYYY-MM), defaults to the current one"
+// @Success 200 {array} models.BudgetStatus
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /budgets/status [get]
+func (s *Server) getBudgetStatus(c *gin.Context) {
+	month := c.DefaultQuery("month", s.now().In(s.location).Format(budgetMonthLayout))
+	if !validBudgetMonth(month) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month: must be YYYY-MM"})
+		return
+	}
+
+	statuses, err := s.storage.GetBudgetStatus(month, s.location.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statuses)
+}
+
+func respondBudgetError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrBudgetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+	case errors.Is(err, storage.ErrUniqueViolation):
+		c.JSON(http.StatusConflict, gin.H{"error": "A budget for that category and month already exists"})
+	default:
+		respondStorageError(c, err)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bulkUpdateRequest is the body of PUT /items/bulk: every sale matching
+// Filter gets the non-null fields of Set.
+type bulkUpdateRequest struct {
+	Filter bulkFilter `json:"filter"`
+	Set    bulkFields `json:"set"`
+}
+
+// bulkFilter selects the sales a bulk update touches. At least one field
+// must be set.
+type bulkFilter struct {
+	Type     string     `json:"type"`
+	Category string     `json:"category"`
+	From     *time.Time `json:"from"`
+	To       *time.Time `json:"to"`
+}
+
+// bulkFields are the fields a bulk update may change.
+type bulkFields struct {
+	Type     *string   `json:"type"`
+	Category *string   `json:"category"`
+	Tags     *[]string `json:"tags"`
+	Note     *string   `json:"note"`
+}
+
+// bulkUpdateSales applies the same correction to every sale matching a
+// filter in a single statement, e.g. to move a month of miscategorized
+// expenses. An empty filter is rejected, and as with bulk delete the request
+// must carry ?confirm=true. Locked sales are left alone unless forced.
+//
+// @Summary Update all sales matching a filter
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param request body bulkUpdateRequest true "Filter and fields to set"
+// @Param confirm query bool true "Must be true"
+// @Param force query bool false "Override sale locks (requires X-Admin-Key)"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/bulk [put]
+func (s *Server) bulkUpdateSales(c *gin.Context) {
+	var req bulkUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	filter, err := req.Filter.saleFilter()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter: " + err.Error()})
+		return
+	}
+	fields, err := s.bulkFieldValues(req.Set)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid set: " + err.Error()})
+		return
+	}
+	if c.Query("confirm") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bulk update requires ?confirm=true"})
+		return
+	}
+
+	force, ok := s.forceRequested(c)
+	if !ok {
+		return
+	}
+
+	updated, err := s.storage.BulkUpdateSales(filter, fields, force)
+	if err != nil {
+		respondStorageError(c, err)
+		return
+	}
+
+	if updated > 0 {
+		s.salesChanged("sales.updated", gin.H{"filter": req.Filter, "count": updated})
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// saleFilter validates f and converts it to a models.SaleFilter.
+func (f bulkFilter) saleFilter() (models.SaleFilter, error) {
+	filter := models.SaleFilter{From: f.From, To: f.To, Category: strings.TrimSpace(f.Category)}
+	if f.Type != "" {
+		if !saleTypes[f.Type] {
+			return filter, errors.New("type must be income or expense")
+		}
+		filter.Type = f.Type
+	}
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return filter, errors.New("from must not be after to")
+	}
+	if filter.From == nil && filter.To == nil && filter.Type == "" && filter.Category == "" {
+		return filter, errors.New("at least one of type, category, from or to is required")
+	}
+	return filter, nil
+}
+
+// bulkFieldValues validates the fields to set the way normalizeSale would and
+// returns them keyed by column.
+func (s *Server) bulkFieldValues(set bulkFields) (map[string]any, error) {
+	fields := make(map[string]any)
+	if set.Type != nil {
+		saleType := *set.Type
+		if !s.cfg.Server.StrictSaleTypes {
+			saleType = strings.ToLower(strings.TrimSpace(saleType))
+		}
+		if !saleTypes[saleType] {
+			return nil, fmt.Errorf("unknown sale type %q: must be income or expense", *set.Type)
+		}
+		fields["type"] = saleType
+	}
+	if set.Category != nil {
+		category := strings.TrimSpace(*set.Category)
+		if category == "" {
+			return nil, errors.New("category must not be blank")
+		}
+		fields["category"] = category
+	}
+	if set.Tags != nil {
+		tags, err := normalizeTags(*set.Tags)
+		if err != nil {
+			return nil, err
+		}
+		fields["tags"] = tags
+	}
+	if set.Note != nil {
+		fields["note"] = strings.TrimSpace(*set.Note)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+	return fields, nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkUpdateSales(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotFilter models.SaleFilter
+	var gotFields map[string]any
+	calls := 0
+	srv := NewServer(&mockStore{bulkUpdate: func(filter models.SaleFilter, fields map[string]any, force bool) (int64, error) {
+		calls++
+		gotFilter, gotFields = filter, fields
+		return 3, nil
+	}}, &models.Config{})
+
+	body := `{"filter":{"type":"expense","category":" Food ","from":"2024-01-01T00:00:00Z","to":"2024-01-31T23:59:59Z"},
+		"set":{"category":" Groceries ","tags":["Home"]}}`
+	w := serve(srv, http.MethodPut, "/api/items/bulk?confirm=true", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"updated":3}`, w.Body.String())
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "expense", gotFilter.Type)
+	assert.Equal(t, "Food", gotFilter.Category)
+	require.NotNil(t, gotFilter.From)
+	assert.True(t, from.Equal(*gotFilter.From))
+	assert.Equal(t, map[string]any{"category": "Groceries", "tags": []string{"home"}}, gotFields)
+
+	for name, tc := range map[string]struct {
+		path, body string
+	}{
+		"missing confirm": {"/api/items/bulk", `{"filter":{"type":"expense"},"set":{"category":"Food"}}`},
+		"empty filter":    {"/api/items/bulk?confirm=true", `{"filter":{},"set":{"category":"Food"}}`},
+		"bad filter type": {"/api/items/bulk?confirm=true", `{"filter":{"type":"refund"},"set":{"category":"Food"}}`},
+		"inverted range":  {"/api/items/bulk?confirm=true", `{"filter":{"from":"2024-02-01T00:00:00Z","to":"2024-01-01T00:00:00Z"},"set":{"category":"Food"}}`},
+		"nothing to set":  {"/api/items/bulk?confirm=true", `{"filter":{"type":"expense"},"set":{}}`},
+		"blank category":  {"/api/items/bulk?confirm=true", `{"filter":{"type":"expense"},"set":{"category":"  "}}`},
+		"bad set type":    {"/api/items/bulk?confirm=true", `{"filter":{"type":"expense"},"set":{"type":"refund"}}`},
+	} {
+		w := serve(srv, http.MethodPut, tc.path, tc.body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+	assert.Equal(t, 1, calls, "rejected requests never reach the store")
+}
+
+func TestBulkUpdateSales_ForceRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Server.AdminKey = "secret"
+	srv := NewServer(nil, cfg)
+
+	w := serve(srv, http.MethodPut, "/api/items/bulk?confirm=true&force=true", `{"filter":{"type":"expense"},"set":{"note":"x"}}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
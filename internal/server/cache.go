@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"L3_6/models"
+)
+
+// analyticsCache keeps recent GetAnalytics results for a short TTL. Keys
+// include the range's last modification and count, so sales written behind
+// the API's back (e.g. by the recurring scheduler) miss the cache too. Any
+// sale change through the API also clears it; results computed while a change
+// was in flight are dropped rather than cached, so a cleared entry can't be
+// resurrected stale.
+type analyticsCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]analyticsCacheEntry
+	generation uint64
+}
+
+type analyticsCacheEntry struct {
+	analytics *models.AnalyticsResponse
+	expires   time.Time
+}
+
+func newAnalyticsCache(ttl time.Duration, now func() time.Time) *analyticsCache {
+	return &analyticsCache{ttl: ttl, now: now, entries: make(map[string]analyticsCacheEntry)}
+}
+
+func analyticsCacheKey(from, to time.Time, percentiles []float64, modified time.Time, count int64) string {
+	return fmt.Sprintf("%d|%d|%v|%d|%d", from.UnixNano(), to.UnixNano(), percentiles, modified.UnixNano(), count)
+}
+
+// cloneAnalytics deep-copies analytics, so callers may annotate the result
+// without touching what the cache holds.
+func cloneAnalytics(analytics *models.AnalyticsResponse) *models.AnalyticsResponse {
+	clone := *analytics
+	if analytics.SavingsRate != nil {
+		rate := *analytics.SavingsRate
+		clone.SavingsRate = &rate
+	}
+	if analytics.ExpenseRatio != nil {
+		ratio := *analytics.ExpenseRatio
+		clone.ExpenseRatio = &ratio
+	}
+	clone.Percentiles = maps.Clone(analytics.Percentiles)
+	return &clone
+}
+
+// get returns a copy of the cached analytics for key, if still fresh, and
+// the generation to pass to put after computing them on a miss.
+func (c *analyticsCache) get(key string) (*models.AnalyticsResponse, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, c.generation, false
+	}
+	return cloneAnalytics(entry.analytics), c.generation, true
+}
+
+// put caches analytics under key unless the cache was invalidated since
+// generation was read. Expired entries are swept on the way.
+func (c *analyticsCache) put(key string, generation uint64, analytics *models.AnalyticsResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	now := c.now()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = analyticsCacheEntry{analytics: cloneAnalytics(analytics), expires: now.Add(c.ttl)}
+}
+
+// invalidate drops every entry.
+func (c *analyticsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
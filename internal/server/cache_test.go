@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsCache(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	cache := newAnalyticsCache(time.Minute, func() time.Time { return now })
+	want := &models.AnalyticsResponse{Count: 3, Sum: decimal.NewFromInt(60), Percentiles: map[string]float64{"p50": 20}}
+
+	_, generation, ok := cache.get("k")
+	assert.False(t, ok)
+	cache.put("k", generation, want)
+
+	got, _, ok := cache.get("k")
+	require.True(t, ok)
+	assert.Equal(t, 3, got.Count)
+	got.Count = 99
+	got.Percentiles["p50"] = 99
+	got, _, _ = cache.get("k")
+	assert.Equal(t, 3, got.Count, "callers get a copy")
+	assert.Equal(t, 20.0, got.Percentiles["p50"], "the copy is deep")
+
+	now = now.Add(time.Minute)
+	_, _, ok = cache.get("k")
+	assert.False(t, ok, "entries expire after the TTL")
+
+	_, generation, _ = cache.get("k")
+	cache.invalidate()
+	cache.put("k", generation, want)
+	_, _, ok = cache.get("k")
+	assert.False(t, ok, "results computed across an invalidation are not cached")
+}
+
+func TestGetAnalytics_Cache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	queries, count := 0, int64(0)
+	store := &mockStore{
+		lastModified: func(_, _ time.Time) (time.Time, int64, error) { return time.Time{}, count, nil },
+		getAnalytics: func(_, _ time.Time, _ ...float64) (*models.AnalyticsResponse, error) {
+			queries++
+			return &models.AnalyticsResponse{}, nil
+		},
+		createSale: func(*models.Sale) error { return nil },
+	}
+	cfg := &models.Config{}
+	cfg.Analytics.CacheTTL = time.Minute
+	srv := NewServer(store, cfg)
+	get := func(query string) {
+		w := serve(srv, http.MethodGet, "/api/analytics?from=2024-01-01&to=2024-01-31"+query, "")
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	get("")
+	get("")
+	assert.Equal(t, 1, queries, "a cache hit doesn't query the store")
+
+	get("&percentiles=75")
+	assert.Equal(t, 2, queries, "the key includes the percentiles")
+
+	get("&nocache=true")
+	assert.Equal(t, 3, queries, "nocache bypasses the cache")
+
+	w := serve(srv, http.MethodPost, "/api/items", `{"type":"expense","amount":"5","date":"2024-01-10T10:00:00Z","category":"Food"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	get("")
+	assert.Equal(t, 4, queries, "creating a sale invalidates the cache")
+
+	// A sale the recurring scheduler created never went through the API.
+	count++
+	get("")
+	assert.Equal(t, 5, queries, "a changed range misses the cache")
+}
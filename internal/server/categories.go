@@ -0,0 +1,202 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"L3_6/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type categoryRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// bindCategoryName reads and trims the category name from the body. On
+// invalid input it writes a 400 response and returns ok=false.
+func bindCategoryName(c *gin.Context) (string, bool) {
+	var req categoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return "", false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return "", false
+	}
+	if len(name) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be at most 255 characters"})
+		return "", false
+	}
+	return name, true
+}
+
+// @Summary List categories
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.Category
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /categories [get]
+func (s *Server) listCategories(c *gin.Context) {
+	categories, err := s.storage.ListCategories()
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// listCategoryUsage returns the distinct category names found on sales with
+// their counts, for autocomplete. Unlike listCategories it includes names
+// that aren't managed categories.
+//
+// @Summary List category names used on sales
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.CategoryUsage
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /categories/usage [get]
+func (s *Server) listCategoryUsage(c *gin.Context) {
+	usage, err := s.storage.GetCategories()
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// @Summary Get a category
+// @Tags categories
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /categories/{id} [get]
+func (s *Server) getCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	category, err := s.storage.GetCategory(id)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// @Summary Create a category
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param category body categoryRequest true "Category"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /categories [post]
+func (s *Server) createCategory(c *gin.Context) {
+	name, ok := bindCategoryName(c)
+	if !ok {
+		return
+	}
+
+	category, linked, err := s.storage.CreateCategory(name)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+	if linked > 0 {
+		s.salesChanged("sales.updated", gin.H{"category_id": category.ID, "category": category.Name, "count": linked})
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// @Summary Rename a category
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param category body categoryRequest true "Category"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /categories/{id} [put]
+func (s *Server) renameCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	name, ok := bindCategoryName(c)
+	if !ok {
+		return
+	}
+
+	category, updated, err := s.storage.RenameCategory(id, name)
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+	if updated > 0 {
+		s.salesChanged("sales.updated", gin.H{"category_id": category.ID, "category": category.Name, "count": updated})
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// @Summary Delete a category
+// @Tags categories
+// @Produce json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /categories/{id} [delete]
+func (s *Server) deleteCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := s.storage.DeleteCategory(id); err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, storage.ErrUniqueViolation):
+		c.JSON(http.StatusConflict, gin.H{"error": "A category with that name already exists"})
+	default:
+		respondStorageError(c, err)
+	}
+}
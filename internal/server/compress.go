@@ -0,0 +1,195 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// incompressibleTypes are content type prefixes of formats that are already
+// compressed, so gzipping them again only costs CPU.
+var incompressibleTypes = []string{
+	"image/",
+	"application/zip",
+	"application/gzip",
+	"application/pdf",
+	"application/vnd.openxmlformats-",
+}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// compress gzips responses for clients that accept it once the body reaches
+// cfg.Server.CompressionMinBytes; smaller bodies go out as they are. Bodies
+// the handler already encoded, and formats that are compressed anyway, are
+// passed through. With compression disabled it does nothing.
+func compress(cfg *models.Config) gin.HandlerFunc {
+	if !cfg.Server.Compression {
+		return func(c *gin.Context) { c.Next() }
+	}
+	minBytes := cfg.Server.CompressionMinBytes
+
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipWriter{ResponseWriter: c.Writer, minBytes: minBytes}
+		c.Writer = w
+		c.Next()
+		w.finish()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(name, "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter buffers the start of a response until it knows whether to
+// compress it: once minBytes have been written or the handler flushes, the
+// body is gzipped if eligible; a response that finishes below minBytes is
+// written as is.
+type gzipWriter struct {
+	gin.ResponseWriter
+	minBytes int64
+
+	buf      []byte
+	decided  bool
+	gz       *gzip.Writer
+	writeErr error
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if int64(len(w.buf)) < w.minBytes {
+			return len(p), nil
+		}
+		w.decide(true)
+		if w.writeErr != nil {
+			return 0, w.writeErr
+		}
+		return len(p), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow commits the headers, so anything buffered is sent without
+// compression.
+func (w *gzipWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *gzipWriter) Written() bool {
+	return w.ResponseWriter.Written() || len(w.buf) > 0
+}
+
+// Flush lets streaming handlers push data out. A response whose size is
+// still unknown at the first flush is compressed if eligible.
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide settles whether the body is gzipped, which it is when want is set
+// and the response is eligible, and writes out the buffered bytes.
+func (w *gzipWriter) decide(want bool) {
+	w.decided = true
+	if want && w.compressible() {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return
+	}
+	if w.gz != nil {
+		_, w.writeErr = w.gz.Write(buf)
+	} else {
+		_, w.writeErr = w.ResponseWriter.Write(buf)
+	}
+}
+
+// compressible reports whether the response may be gzipped: it has a body,
+// isn't already encoded and isn't a compressed format.
+func (w *gzipWriter) compressible() bool {
+	switch status := w.Status(); {
+	case status < http.StatusOK, status == http.StatusNoContent, status == http.StatusPartialContent, status == http.StatusNotModified:
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// finish sends a response that stayed below the threshold and completes the
+// gzip stream of one that didn't.
+func (w *gzipWriter) finish() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		_ = w.gz.Close()
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compressedRouter(enabled bool, minBytes int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Server.Compression = enabled
+	cfg.Server.CompressionMinBytes = minBytes
+
+	r := gin.New()
+	r.Use(compress(cfg))
+	r.GET("/big", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("sale ", 1000)) })
+	r.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+	r.GET("/empty", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", bytes.Repeat([]byte{1}, 4096)) })
+	r.GET("/stream", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString("first")
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString(" second")
+	})
+	return r
+}
+
+func get(r http.Handler, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func gunzip(t *testing.T, body []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCompress(t *testing.T) {
+	r := compressedRouter(true, 1024)
+
+	w := get(r, "/big", "gzip, deflate")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Less(t, w.Body.Len(), 5000)
+	assert.Equal(t, strings.Repeat("sale ", 1000), gunzip(t, w.Body.Bytes()))
+
+	w = get(r, "/big", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "client didn't ask for gzip")
+	assert.Equal(t, 5000, w.Body.Len())
+
+	w = get(r, "/big", "gzip;q=0")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "gzip explicitly refused")
+
+	w = get(r, "/small", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "below the threshold")
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	w = get(r, "/empty", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Zero(t, w.Body.Len())
+
+	w = get(r, "/image", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "already compressed formats pass through")
+	assert.Equal(t, 4096, w.Body.Len())
+
+	w = get(r, "/stream", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "a flushed response of unknown size is compressed")
+	assert.Equal(t, "first second", gunzip(t, w.Body.Bytes()))
+}
+
+func TestCompress_Disabled(t *testing.T) {
+	w := get(compressedRouter(false, 0), "/big", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Vary"))
+	assert.Equal(t, 5000, w.Body.Len())
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                     false,
+		"gzip":                 true,
+		"deflate, GZIP":        true,
+		"br;q=1.0, gzip;q=0.8": true,
+		"*":                    true,
+		"gzip;q=0":             false,
+		"gzip; q=0.000":        false,
+		"identity":             false,
+	} {
+		assert.Equal(t, want, acceptsGzip(header), header)
+	}
+}
+
+func TestExportSales_Compressed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sales := make([]models.Sale, 200)
+	for i := range sales {
+		sales[i] = models.Sale{ID: i + 1, Type: "expense", Amount: decimal.RequireFromString("9.99"), Date: day, Category: "Food"}
+	}
+	cfg := &models.Config{}
+	cfg.Server.Compression = true
+	cfg.Server.CompressionMinBytes = 1024
+	srv := NewServer(&mockStore{getSalesFiltered: func(models.SaleFilter) ([]models.Sale, error) { return sales, nil }}, cfg)
+
+	w := get(srv.router, "/api/export", "gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+
+	records, err := csv.NewReader(strings.NewReader(gunzip(t, w.Body.Bytes()))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(sales)+1)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, "200", records[200][0])
+
+	w = get(srv.router, "/api/export?format=xlsx", "gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "xlsx is already a zip archive")
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(srv *Server, method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/health", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("disabled without configured origins", func(t *testing.T) {
+		srv := NewServer(nil, &models.Config{})
+		w := request(srv, http.MethodGet, "https://app.example.com")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	cfg := &models.Config{}
+	cfg.Server.AllowedOrigins = []string{"https://app.example.com"}
+	srv := NewServer(nil, cfg)
+
+	t.Run("allowed origin is echoed", func(t *testing.T) {
+		w := request(srv, http.MethodGet, "https://app.example.com")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("preflight is answered", func(t *testing.T) {
+		w := request(srv, http.MethodOptions, "https://app.example.com")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "DELETE")
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Content-Type")
+	})
+
+	t.Run("other origins get no CORS headers", func(t *testing.T) {
+		w := request(srv, http.MethodGet, "https://evil.example.com")
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"L3_6/models"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	// nextCursorHeader carries the cursor of the next page of a listing.
+	nextCursorHeader = "X-Next-Cursor"
+)
+
+// encodeCursor renders a listing position as an opaque, URL-safe token.
+func encodeCursor(cursor models.SaleCursor) string {
+	raw := cursor.Date.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token produced by encodeCursor.
+func decodeCursor(token string) (*models.SaleCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	date, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("malformed cursor")
+	}
+
+	var cursor models.SaleCursor
+	if cursor.Date, err = time.Parse(time.RFC3339Nano, date); err != nil {
+		return nil, fmt.Errorf("malformed cursor date: %w", err)
+	}
+	if cursor.ID, err = strconv.Atoi(id); err != nil {
+		return nil, fmt.Errorf("malformed cursor id: %w", err)
+	}
+	return &cursor, nil
+}
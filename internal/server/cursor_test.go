@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := models.SaleCursor{Date: time.Date(2024, 1, 15, 10, 30, 0, 123456789, time.UTC), ID: 42}
+
+	got, err := decodeCursor(encodeCursor(want))
+	require.NoError(t, err)
+	assert.True(t, want.Date.Equal(got.Date))
+	assert.Equal(t, 42, got.ID)
+
+	for _, token := range []string{"", "not base64!", encodeCursor(want)[:5]} {
+		_, err := decodeCursor(token)
+		assert.Error(t, err, token)
+	}
+}
+
+func TestGetSales_Pagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	day := time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)
+	var got models.SaleFilter
+	store := &mockStore{getSalesFiltered: func(filter models.SaleFilter) ([]models.Sale, error) {
+		got = filter
+		// Three sales exist; serve at most filter.Limit of them.
+		sales := []models.Sale{{ID: 3, Date: day}, {ID: 2, Date: day}, {ID: 1, Date: day.AddDate(0, 0, -1)}}
+		if filter.Limit > 0 && filter.Limit < len(sales) {
+			sales = sales[:filter.Limit]
+		}
+		return sales, nil
+	}}
+	srv := NewServer(store, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Zero(t, got.Limit, "listings without pagination parameters stay unbounded")
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"))
+
+	w = serve(srv, http.MethodGet, "/api/items?limit=2", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, got.Limit, "one extra sale is fetched to detect a next page")
+	var page []models.Sale
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page, 2)
+	next := w.Header().Get("X-Next-Cursor")
+	cursor, err := decodeCursor(next)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cursor.ID)
+
+	req := "/api/items?limit=2&after=" + next
+	w = serve(srv, http.MethodGet, req, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, got.After)
+	assert.Equal(t, 2, got.After.ID)
+
+	w = serve(srv, http.MethodGet, "/api/items?limit=5", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Next-Cursor"), "the last page has no cursor")
+
+	w = serve(srv, http.MethodGet, "/api/items?after="+next, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, defaultPageSize+1, got.Limit)
+
+	serve(srv, http.MethodGet, "/api/items?limit=100000", "")
+	assert.Equal(t, maxPageSize+1, got.Limit)
+
+	for _, query := range []string{"limit=0", "limit=x", "after=bogus", "limit=2&sort=amount"} {
+		assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/items?"+query, "").Code, query)
+	}
+}
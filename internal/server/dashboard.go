@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dashboardListSize is how many top categories and recent sales the
+// dashboard includes.
+const dashboardListSize = 5
+
+// getDashboard assembles a dashboard home screen for a range in one call,
+// saving clients separate analytics, category and listing requests.
+//
+// @Summary Summarize a range for a dashboard
+// @Tags analytics
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Success 200 {object} models.DashboardResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /dashboard [get]
+func (s *Server) getDashboard(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	modified, count, err := s.storage.LastModified(from, to)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	analytics, err := s.cachedAnalytics(from, to, nil, modified, count, false)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	markReliability(analytics, s.cfg.Analytics.MinSampleSize)
+	setAvgPerDay(analytics, from, to, loc)
+
+	top, err := s.storage.GetTopCategories(from, to, "expense", dashboardListSize)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	recent, err := s.storage.GetSalesFiltered(models.SaleFilter{From: &from, To: &to, Sort: "date", Limit: dashboardListSize})
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	if recent == nil {
+		recent = []models.Sale{}
+	}
+
+	c.JSON(http.StatusOK, models.DashboardResponse{
+		From:          from,
+		To:            to,
+		Analytics:     *analytics,
+		Income:        models.TypeTotal{Total: analytics.IncomeSum, Count: analytics.IncomeCount},
+		Expense:       models.TypeTotal{Total: analytics.ExpenseSum, Count: analytics.ExpenseCount},
+		TopCategories: top,
+		Recent:        recent,
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDashboard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var (
+		topType, topLimit = "", 0
+		listed            models.SaleFilter
+	)
+	store := &mockStore{
+		lastModified: func(_, _ time.Time) (time.Time, int64, error) { return time.Time{}, 0, nil },
+		getAnalytics: func(_, _ time.Time, _ ...float64) (*models.AnalyticsResponse, error) {
+			return &models.AnalyticsResponse{
+				Count:        3,
+				IncomeSum:    decimal.NewFromInt(100),
+				IncomeCount:  1,
+				ExpenseSum:   decimal.NewFromInt(40),
+				ExpenseCount: 2,
+			}, nil
+		},
+		getTopCategories: func(_, _ time.Time, saleType string, limit int) ([]models.CategoryTotal, error) {
+			topType, topLimit = saleType, limit
+			return []models.CategoryTotal{{Category: "Food", Total: decimal.NewFromInt(40)}}, nil
+		},
+		getSalesFiltered: func(filter models.SaleFilter) ([]models.Sale, error) {
+			listed = filter
+			return nil, nil
+		},
+	}
+	srv := NewServer(store, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/dashboard?from=2024-01-01&to=2024-01-31", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var got models.DashboardResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, 3, got.Analytics.Count)
+	assert.True(t, got.Income.Total.Equal(decimal.NewFromInt(100)))
+	assert.Equal(t, 2, got.Expense.Count)
+	require.Len(t, got.TopCategories, 1)
+	assert.Equal(t, "Food", got.TopCategories[0].Category)
+	assert.NotNil(t, got.Recent)
+	assert.Empty(t, got.Recent)
+	assert.True(t, got.To.Equal(time.Date(2024, 1, 31, 23, 59, 59, 999999999, time.UTC)))
+
+	assert.Equal(t, "expense", topType)
+	assert.Equal(t, dashboardListSize, topLimit)
+	assert.Equal(t, dashboardListSize, listed.Limit)
+	assert.Equal(t, "date", listed.Sort)
+	assert.False(t, listed.Ascending, "most recent first")
+
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/dashboard?to=2024-01-31", "").Code)
+}
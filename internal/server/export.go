@@ -0,0 +1,510 @@
+package server
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+var csvHeader = []string{"id", "type", "amount", "date", "category", "note", "payment_method"}
+
+// @Summary Export sales
+// @Tags import-export
+// @Produce text/csv
+// @Produce application/zip
+// @Produce json
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "File format" Enums(csv, zip, json, xlsx) default(csv)
+// @Param group_by query string false "Group rows (csv and zip only)" Enums(category)
+// @Param from query string false "Earliest date (RFC3339)"
+// @Param to query string false "Latest date (RFC3339)"
+// @Param near query string false "Proximity filter as lat,lng,radiuskm"
+// @Param sort query string false "Sort column" Enums(date, amount, category, id) default(date)
+// @Param order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Param type query string false "Sale type" Enums(income, expense)
+// @Param category query string false "Category, ignoring case"
+// @Param method query string false "Payment method, ignoring case"
+// @Param uncategorized query bool false "Only sales in the default category"
+// @Param tag query []string false "Only sales carrying every given tag; repeat for several" collectionFormat(multi)
+// @Param tz query string false "IANA time zone for the zip report's per-day analytics; defaults to analytics.timezone"
+// @Success 200 {file} file
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /export [get]
+func (s *Server) exportSales(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "zip" && format != "json" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+		return
+	}
+
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "category" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_by"})
+		return
+	}
+	if groupBy != "" && format != "csv" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by is only supported for csv and zip exports"})
+		return
+	}
+
+	filter, ok := s.parseSaleFilter(c)
+	if !ok {
+		return
+	}
+	// The report's per-day figures follow the calendar of tz, as in
+	// /analytics.
+	loc := time.UTC
+	if format == "zip" {
+		if loc, ok = s.parseTimezone(c); !ok {
+			return
+		}
+	}
+
+	// Plain CSV and JSON are written while the sales are read; grouping,
+	// workbooks and reports need the whole set first.
+	if format == "json" || format == "csv" && groupBy == "" {
+		s.streamExport(c, filter, format)
+		return
+	}
+
+	sales, err := s.storage.GetSalesFiltered(filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", `attachment; filename="sales.xlsx"`)
+		c.Status(http.StatusOK)
+		if err := writeXLSX(c.Writer, sales); err != nil {
+			c.Error(err)
+		}
+		return
+	}
+
+	writeSales := writeCSV
+	if groupBy == "category" {
+		writeSales = writeGroupedCSV
+	}
+
+	// The report's analytics cover exactly the exported sales.
+	if format == "zip" {
+		from, to := reportRange(filter, sales)
+		analytics, err := s.storage.GetAnalyticsFiltered(filter)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		markReliability(analytics, s.cfg.Analytics.MinSampleSize)
+		setAvgPerDay(analytics, from, to, loc)
+
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", `attachment; filename="sales-report.zip"`)
+		c.Status(http.StatusOK)
+		if err := writeReportZip(c.Writer, sales, analytics, writeSales); err != nil {
+			c.Error(err)
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="sales.csv"`)
+	c.Status(http.StatusOK)
+
+	if err := writeSales(c.Writer, sales); err != nil {
+		c.Error(err)
+	}
+}
+
+// streamExport writes a CSV or JSON export as the sales are read from the
+// database, so memory use stays flat however many sales match. The response
+// starts with the first sale: a failure before it is answered with a 500,
+// one after it can only cut the download short.
+func (s *Server) streamExport(c *gin.Context, filter models.SaleFilter, format string) {
+	contentType, filename := "text/csv", "sales.csv"
+	var enc saleEncoder = newCSVEncoder(c.Writer)
+	if format == "json" {
+		contentType, filename = "application/json", "sales.json"
+		enc = &jsonEncoder{w: c.Writer}
+	}
+
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Status(http.StatusOK)
+		return enc.begin()
+	}
+
+	err := s.storage.StreamSales(c.Request.Context(), filter, func(sale models.Sale) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return enc.encode(sale)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = enc.end()
+	}
+	if err != nil {
+		if !started {
+			respondInternalError(c, err)
+			return
+		}
+		c.Error(err)
+	}
+}
+
+// reportRange returns the analytics range for an export: the requested bounds,
+// with open ends closed at the earliest and latest exported sale.
+func reportRange(filter models.SaleFilter, sales []models.Sale) (from, to time.Time) {
+	for _, sale := range sales {
+		if from.IsZero() || sale.Date.Before(from) {
+			from = sale.Date
+		}
+		if sale.Date.After(to) {
+			to = sale.Date
+		}
+	}
+	if filter.From != nil {
+		from = *filter.From
+	}
+	if filter.To != nil {
+		to = *filter.To
+	}
+	return from, to
+}
+
+// writeReportZip streams a zip with the sales CSV and the analytics as JSON.
+func writeReportZip(w io.Writer, sales []models.Sale, analytics *models.AnalyticsResponse, writeSales func(io.Writer, []models.Sale) error) error {
+	zw := zip.NewWriter(w)
+
+	csvFile, err := zw.Create("sales.csv")
+	if err != nil {
+		return err
+	}
+	if err := writeSales(csvFile, sales); err != nil {
+		return err
+	}
+
+	jsonFile, err := zw.Create("analytics.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(jsonFile)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(analytics); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeCSV(w io.Writer, sales []models.Sale) error {
+	return encodeSales(newCSVEncoder(w), sales)
+}
+
+// writeJSON writes sales as a JSON array, one element at a time, in the same
+// shape the API returns them. No sales yields an empty array.
+func writeJSON(w io.Writer, sales []models.Sale) error {
+	return encodeSales(&jsonEncoder{w: w}, sales)
+}
+
+// exportFlushRows is how many sales an export encoder writes between pushing
+// the response out to the client.
+const exportFlushRows = 500
+
+// saleEncoder writes an export one sale at a time. begin writes what comes
+// before the first sale and end what comes after the last.
+type saleEncoder interface {
+	begin() error
+	encode(sale models.Sale) error
+	end() error
+}
+
+func encodeSales(enc saleEncoder, sales []models.Sale) error {
+	if err := enc.begin(); err != nil {
+		return err
+	}
+	for _, sale := range sales {
+		if err := enc.encode(sale); err != nil {
+			return err
+		}
+	}
+	return enc.end()
+}
+
+// csvEncoder writes the CSV export: a csvHeader row, then one saleRecord per
+// sale.
+type csvEncoder struct {
+	w    io.Writer
+	cw   *csv.Writer
+	rows int
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: w, cw: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) begin() error {
+	return e.cw.Write(csvHeader)
+}
+
+func (e *csvEncoder) encode(sale models.Sale) error {
+	if err := e.cw.Write(saleRecord(sale)); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		e.cw.Flush()
+		if err := e.cw.Error(); err != nil {
+			return err
+		}
+		flushWriter(e.w)
+	}
+	return nil
+}
+
+func (e *csvEncoder) end() error {
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+// jsonEncoder writes the JSON export as an array of sales.
+type jsonEncoder struct {
+	w    io.Writer
+	rows int
+}
+
+func (e *jsonEncoder) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonEncoder) encode(sale models.Sale) error {
+	if e.rows > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(sale)
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		flushWriter(e.w)
+	}
+	return nil
+}
+
+func (e *jsonEncoder) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// flushWriter pushes buffered output on to the client when w is an HTTP
+// response.
+func flushWriter(w io.Writer) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeXLSX writes sales as a single-sheet workbook with the CSV columns, a
+// bold header row, amounts in a two-decimal money format, and income and
+// expense totals at the bottom computed by SUMIF formulas.
+func writeXLSX(w io.Writer, sales []models.Sale) error {
+	const sheet = "Sales"
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	moneyFormat := "#,##0.00"
+	moneyStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &moneyFormat})
+	if err != nil {
+		return err
+	}
+	totalStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: &moneyFormat})
+	if err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(4, 4, 20); err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(5, 5, 20); err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(6, 6, 40); err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(7, 7, 16); err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(csvHeader))
+	for i, name := range csvHeader {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: name}
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	for i, sale := range sales {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		row := []interface{}{
+			sale.ID,
+			sale.Type,
+			excelize.Cell{StyleID: moneyStyle, Value: sale.Amount.InexactFloat64()},
+			sale.Date,
+			sale.Category,
+			truncateNote(sale.Note),
+			sale.PaymentMethod,
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+
+	last := len(sales) + 1
+	for i, saleType := range []string{"income", "expense"} {
+		cell, _ := excelize.CoordinatesToCellName(1, last+i+1)
+		total := excelize.Cell{StyleID: totalStyle, Value: 0}
+		if len(sales) > 0 {
+			total.Formula = fmt.Sprintf(`SUMIF(B2:B%[1]d,"%[2]s",C2:C%[1]d)`, last, saleType)
+		}
+		row := []interface{}{
+			excelize.Cell{StyleID: headerStyle, Value: "total"},
+			excelize.Cell{StyleID: headerStyle, Value: saleType},
+			total,
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	return f.Write(w)
+}
+
+// writeGroupedCSV writes sales grouped by category, in category order, with a
+// subtotal row after each group and a grand total row at the end. Subtotals
+// and the total are net amounts, income minus expenses. Within a group the
+// incoming order is preserved.
+func writeGroupedCSV(w io.Writer, sales []models.Sale) error {
+	sorted := make([]models.Sale, len(sales))
+	copy(sorted, sales)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Category < sorted[j].Category
+	})
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	total := decimal.Zero
+	for i := 0; i < len(sorted); {
+		category := sorted[i].Category
+		subtotal := decimal.Zero
+		for ; i < len(sorted) && sorted[i].Category == category; i++ {
+			if err := cw.Write(saleRecord(sorted[i])); err != nil {
+				return err
+			}
+			subtotal = subtotal.Add(sorted[i].SignedAmount())
+		}
+		if err := cw.Write([]string{"", "subtotal", formatAmount(subtotal), "", exportText(category), "", ""}); err != nil {
+			return err
+		}
+		total = total.Add(subtotal)
+	}
+	if err := cw.Write([]string{"", "total", formatAmount(total), "", "", "", ""}); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func saleRecord(sale models.Sale) []string {
+	return []string{
+		strconv.Itoa(sale.ID),
+		sale.Type,
+		formatAmount(sale.Amount),
+		sale.Date.Format(time.RFC3339),
+		exportText(sale.Category),
+		exportNote(sale.Note),
+		exportText(sale.PaymentMethod),
+	}
+}
+
+// maxExportNoteLength caps how many characters of a note an export carries.
+const maxExportNoteLength = 200
+
+// exportNote prepares a free-text note for a CSV cell: truncated by
+// truncateNote, then guarded as by exportText.
+func exportNote(note string) string {
+	return exportText(truncateNote(note))
+}
+
+// truncateNote cuts notes longer than maxExportNoteLength characters, ending
+// them with an ellipsis.
+func truncateNote(note string) string {
+	if utf8.RuneCountInString(note) > maxExportNoteLength {
+		note = string([]rune(note)[:maxExportNoteLength-1]) + "…"
+	}
+	return note
+}
+
+// exportText prepares user-supplied text for a CSV cell: text a spreadsheet
+// would evaluate as a formula on import is prefixed with a quote so it stays
+// text. XLSX cells need no guard, as excelize writes strings as text cells.
+func exportText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		text = "'" + text
+	}
+	return text
+}
+
+func formatAmount(amount decimal.Decimal) string {
+	return amount.StringFixed(2)
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestWriteGroupedCSV(t *testing.T) {
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sales := []models.Sale{
+		{ID: 1, Type: "expense", Amount: decimal.RequireFromString("12.50"), Date: day, Category: "Food"},
+		{ID: 2, Type: "expense", Amount: decimal.RequireFromString("900.00"), Date: day, Category: "Rent"},
+		{ID: 3, Type: "expense", Amount: decimal.RequireFromString("7.25"), Date: day, Category: "Food"},
+		{ID: 4, Type: "income", Amount: decimal.RequireFromString("1000.00"), Date: day, Category: "Salary"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeGroupedCSV(&buf, sales))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, csvHeader, records[0])
+
+	// Recompute each group's sum from its detail rows and compare with the
+	// subtotal row that closes the group.
+	groupSums := map[string]decimal.Decimal{}
+	subtotals := map[string]string{}
+	var categories []string
+	var total string
+	for _, rec := range records[1:] {
+		switch rec[1] {
+		case "subtotal":
+			subtotals[rec[4]] = rec[2]
+			categories = append(categories, rec[4])
+		case "total":
+			total = rec[2]
+		default:
+			amount, err := decimal.NewFromString(rec[2])
+			require.NoError(t, err)
+			groupSums[rec[4]] = groupSums[rec[4]].Add(models.SignedAmount(rec[1], amount))
+		}
+	}
+
+	assert.Equal(t, []string{"Food", "Rent", "Salary"}, categories)
+	for category, sum := range groupSums {
+		assert.Equal(t, formatAmount(sum), subtotals[category], category)
+	}
+	assert.Equal(t, "-19.75", subtotals["Food"])
+	assert.Equal(t, "1000.00", subtotals["Salary"])
+	assert.Equal(t, "80.25", total, "income minus expenses")
+
+	// Detail rows keep their original relative order within a group.
+	assert.Equal(t, "1", records[1][0])
+	assert.Equal(t, "3", records[2][0])
+}
+
+func TestWriteJSON(t *testing.T) {
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sales := []models.Sale{
+		{ID: 1, Type: "expense", Amount: decimal.RequireFromString("12.50"), Date: day, Category: "Food"},
+		{ID: 2, Type: "income", Amount: decimal.RequireFromString("1000.00"), Date: day, Category: "Salary"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, sales))
+
+	// The export must round-trip through the API's own sale representation.
+	var decoded []models.Sale
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Len(t, decoded, 2)
+	assert.Equal(t, 2, decoded[1].ID)
+	assert.True(t, decoded[0].Amount.Equal(decimal.RequireFromString("12.50")))
+
+	buf.Reset()
+	require.NoError(t, writeJSON(&buf, nil))
+	assert.JSONEq(t, "[]", buf.String())
+}
+
+func TestWriteXLSX(t *testing.T) {
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sales := []models.Sale{
+		{ID: 1, Type: "expense", Amount: decimal.RequireFromString("12.50"), Date: day, Category: "Food"},
+		{ID: 2, Type: "income", Amount: decimal.RequireFromString("1000.00"), Date: day, Category: "Salary", PaymentMethod: "transfer"},
+		{ID: 3, Type: "income", Amount: decimal.RequireFromString("5.00"), Date: day, Category: "-Refund", Note: "=1+1", PaymentMethod: "@card"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeXLSX(&buf, sales))
+
+	f, err := excelize.OpenReader(&buf)
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := f.GetRows("Sales")
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, []string{"1", "expense", "12.50"}, rows[1][:3])
+	assert.Equal(t, "transfer", rows[2][6])
+	assert.Equal(t, []string{"-Refund", "=1+1", "@card"}, rows[3][4:7], "text cells are written verbatim")
+	formula, err := f.GetCellFormula("Sales", "F4")
+	require.NoError(t, err)
+	assert.Empty(t, formula)
+
+	style, err := f.GetCellStyle("Sales", "A1")
+	require.NoError(t, err)
+	header, err := f.GetStyle(style)
+	require.NoError(t, err)
+	assert.True(t, header.Font.Bold)
+
+	assert.Equal(t, []string{"total", "income"}, rows[4][:2])
+	formula, err = f.GetCellFormula("Sales", "C5")
+	require.NoError(t, err)
+	assert.Equal(t, `SUMIF(B2:B4,"income",C2:C4)`, formula)
+	formula, err = f.GetCellFormula("Sales", "C6")
+	require.NoError(t, err)
+	assert.Equal(t, `SUMIF(B2:B4,"expense",C2:C4)`, formula)
+}
+
+func TestExportSales_ReportZip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	all := []models.Sale{
+		{ID: 1, Type: "expense", Amount: decimal.RequireFromString("12.50"), Date: day, Category: "Food"},
+		{ID: 2, Type: "income", Amount: decimal.RequireFromString("1000.00"), Date: day, Category: "Salary"},
+	}
+	// matching applies the filters the stub store understands.
+	matching := func(filter models.SaleFilter) []models.Sale {
+		var sales []models.Sale
+		for _, sale := range all {
+			if filter.Type == "" || sale.Type == filter.Type {
+				sales = append(sales, sale)
+			}
+		}
+		return sales
+	}
+	srv := NewServer(&mockStore{
+		getSalesFiltered: func(filter models.SaleFilter) ([]models.Sale, error) { return matching(filter), nil },
+		analyticsFilter: func(filter models.SaleFilter) (*models.AnalyticsResponse, error) {
+			analytics := &models.AnalyticsResponse{}
+			for _, sale := range matching(filter) {
+				analytics.Count++
+				analytics.Sum = analytics.Sum.Add(sale.Amount)
+			}
+			return analytics, nil
+		},
+	}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/export?format=zip&type=expense", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	entries := unzip(t, w.Body.Bytes())
+	require.Contains(t, entries, "sales.csv")
+	require.Contains(t, entries, "analytics.json")
+
+	records, err := csv.NewReader(bytes.NewReader(entries["sales.csv"])).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, "12.50", records[1][2])
+
+	// The analytics describe the same filtered sales as the CSV.
+	var analytics models.AnalyticsResponse
+	require.NoError(t, json.Unmarshal(entries["analytics.json"], &analytics))
+	assert.Equal(t, len(records)-1, analytics.Count)
+	assert.Equal(t, "12.5", analytics.Sum.String())
+}
+
+// unzip returns the contents of each file in a zip archive by name.
+func unzip(t *testing.T, archive []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+
+	entries := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		entries[f.Name] = data
+	}
+	return entries
+}
+
+func TestExportSales_ReportTimezone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(&mockStore{
+		getSalesFiltered: func(models.SaleFilter) ([]models.Sale, error) { return nil, nil },
+		analyticsFilter: func(models.SaleFilter) (*models.AnalyticsResponse, error) {
+			return &models.AnalyticsResponse{Count: 2}, nil
+		},
+	}, &models.Config{})
+
+	avgPerDay := func(query string) float64 {
+		w := serve(srv, http.MethodGet, "/api/export?format=zip&from=2024-01-01T00:00:00Z&to=2024-01-01T23:00:00Z"+query, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var analytics models.AnalyticsResponse
+		require.NoError(t, json.Unmarshal(unzip(t, w.Body.Bytes())["analytics.json"], &analytics))
+		return analytics.AvgPerDay
+	}
+
+	assert.Equal(t, 2.0, avgPerDay(""), "one UTC day")
+	assert.Equal(t, 1.0, avgPerDay("&tz=Asia/Tokyo"), "two days in Tokyo")
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/export?format=zip&tz=Mars/Base", "").Code)
+}
+
+func TestReportRange(t *testing.T) {
+	first := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC)
+	sales := []models.Sale{{Date: last}, {Date: first}}
+
+	from, to := reportRange(models.SaleFilter{}, sales)
+	assert.Equal(t, first, from)
+	assert.Equal(t, last, to)
+
+	requested := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	from, to = reportRange(models.SaleFilter{From: &requested}, sales)
+	assert.Equal(t, requested, from)
+	assert.Equal(t, last, to)
+}
+
+func TestExportNote(t *testing.T) {
+	assert.Equal(t, "", exportNote(""))
+	assert.Equal(t, "paid in cash", exportNote("paid in cash"))
+	assert.Equal(t, "'=HYPERLINK(\"x\")", exportNote(`=HYPERLINK("x")`))
+	assert.Equal(t, "'+1", exportNote("+1"))
+	assert.Equal(t, "'-1", exportNote("-1"))
+	assert.Equal(t, "'@SUM(A1)", exportNote("@SUM(A1)"))
+
+	long := exportNote(strings.Repeat("é", maxExportNoteLength+10))
+	assert.Equal(t, maxExportNoteLength, utf8.RuneCountInString(long))
+	assert.True(t, strings.HasSuffix(long, "…"))
+	assert.Equal(t, strings.Repeat("x", maxExportNoteLength), exportNote(strings.Repeat("x", maxExportNoteLength)))
+}
+
+func TestWriteCSV_Note(t *testing.T) {
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sales := []models.Sale{
+		{ID: 1, Type: "expense", Amount: decimal.RequireFromString("12.50"), Date: day, Category: "Food", Note: "lunch, \"team\"\nsecond line"},
+		{ID: 2, Type: "expense", Amount: decimal.RequireFromString("3.00"), Date: day, Category: "Food", Note: "=1+1", PaymentMethod: "card"},
+		{ID: 3, Type: "expense", Amount: decimal.RequireFromString("1.00"), Date: day, Category: "@SUM(A1)"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeCSV(&buf, sales))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, "Food", records[1][4])
+	assert.Equal(t, "'@SUM(A1)", records[3][4], "categories are guarded like notes")
+	assert.Equal(t, "payment_method", records[0][6])
+	assert.Equal(t, "card", records[2][6])
+	assert.Equal(t, "", records[1][6])
+	assert.Equal(t, "note", records[0][5])
+	assert.Equal(t, "lunch, \"team\"\nsecond line", records[1][5])
+	assert.Equal(t, "'=1+1", records[2][5])
+	assert.Equal(t, "", records[3][5])
+}
+
+func TestExportSales_Stream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sales := make([]models.Sale, 3*exportFlushRows+1)
+	for i := range sales {
+		sales[i] = models.Sale{ID: i + 1, Type: "expense", Amount: decimal.RequireFromString("9.99"), Date: day, Category: "Food"}
+	}
+	var streamErr error
+	srv := NewServer(&mockStore{getSalesFiltered: func(models.SaleFilter) ([]models.Sale, error) { return sales, streamErr }}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/export", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.True(t, w.Flushed, "long exports are flushed as they are written")
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(sales)+1)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, strconv.Itoa(len(sales)), records[len(sales)][0])
+
+	w = serve(srv, http.MethodGet, "/api/export?format=json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var decoded []models.Sale
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded))
+	assert.Len(t, decoded, len(sales))
+
+	// A failure after the first row can only cut the download short.
+	streamErr = errors.New("connection reset")
+	w = serve(srv, http.MethodGet, "/api/export", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "error")
+
+	// One before it is still reported.
+	sales = nil
+	w = serve(srv, http.MethodGet, "/api/export?format=json", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "error")
+
+	streamErr = nil
+	w = serve(srv, http.MethodGet, "/api/export", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, strings.Join(csvHeader, ",")+"\n", w.Body.String())
+	w = serve(srv, http.MethodGet, "/api/export?format=json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+}
+
+func TestExportSales_OutlivesTimeouts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	day := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	sales := make([]models.Sale, exportFlushRows+1)
+	for i := range sales {
+		sales[i] = models.Sale{ID: i + 1, Type: "expense", Amount: decimal.RequireFromString("9.99"), Date: day, Category: "Food"}
+	}
+	cfg := &models.Config{}
+	cfg.Server.RequestTimeout = 20 * time.Millisecond
+	cfg.Server.WriteTimeout = 20 * time.Millisecond
+	srv := NewServer(&mockStore{getSalesFiltered: func(models.SaleFilter) ([]models.Sale, error) {
+		time.Sleep(100 * time.Millisecond)
+		return sales, nil
+	}}, cfg)
+
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = srv.httpServer("")
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/export")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	require.NoError(t, err, "the export is not cut off")
+	assert.Len(t, records, len(sales)+1)
+}
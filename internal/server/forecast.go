@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// forecastMethod labels the projection getForecast makes.
+const forecastMethod = "naive_linear"
+
+// getForecast projects a month's total expenses from its run rate so far and
+// compares the spend in the budgeted categories with the month's budgets. ?month=YYYY-MM defaults
+// to the current month; a past month's projection is simply its total.
+//
+// @Summary Naive linear forecast of a month's expenses
+// @Description Spend to date divided by elapsed days, times the days in the month. This is a naive linear estimate that ignores seasonality and one-off expenses.
+// @Tags analytics
+// @Produce json
+// @Param month query string false "Month (YYYY-MM); defaults to the current month"
+// @Success 200 {object} models.ForecastResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Security BearerAuth
+// @Router /analytics/forecast [get]
+func (s *Server) getForecast(c *gin.Context) {
+	now := s.now().In(s.location)
+	month := c.DefaultQuery("month", now.Format(budgetMonthLayout))
+	start, err := time.ParseInLocation(budgetMonthLayout, month, s.location)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month: must be YYYY-MM"})
+		return
+	}
+	if start.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Month has not started yet"})
+		return
+	}
+
+	// A finished month is projected as of its last instant, so every day
+	// counts as elapsed.
+	asOf := now
+	if end := start.AddDate(0, 1, 0).Add(-time.Nanosecond); end.Before(asOf) {
+		asOf = end
+	}
+
+	toDate, err := s.storage.SumByType("expense", start, asOf)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+	budgets, err := s.storage.GetBudgetStatus(month, s.location.String())
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, forecast(month, toDate, asOf, budgets))
+}
+
+// forecast builds the projection for month as of asOf; see projectPace. The
+// remaining budget only counts what was spent in budgeted categories, so an
+// unbudgeted expense such as rent doesn't eat into a food budget.
+func forecast(month string, toDate decimal.Decimal, asOf time.Time, budgets []models.BudgetStatus) models.ForecastResponse {
+	pace := projectPace(toDate, asOf)
+	resp := models.ForecastResponse{
+		Month:       month,
+		Method:      forecastMethod,
+		ToDate:      pace.ToDate,
+		DaysElapsed: pace.DaysElapsed,
+		DaysInMonth: pace.DaysInMonth,
+		Projected:   pace.Projected,
+	}
+	if len(budgets) > 0 {
+		total, spent := decimal.Zero, decimal.Zero
+		for _, b := range budgets {
+			total = total.Add(b.Limit)
+			spent = spent.Add(b.Spent)
+		}
+		remaining := total.Sub(spent)
+		resp.Budget, resp.Remaining = &total, &remaining
+	}
+	return resp
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetForecast(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotFrom, gotTo time.Time
+	var gotMonth, gotTZ string
+	budgets := []models.BudgetStatus{}
+	store := &mockStore{
+		sumByType: func(saleType string, from, to time.Time) (decimal.Decimal, error) {
+			assert.Equal(t, "expense", saleType)
+			gotFrom, gotTo = from, to
+			return decimal.RequireFromString("450.00"), nil
+		},
+		budgetStatus: func(month, tz string) ([]models.BudgetStatus, error) {
+			gotMonth, gotTZ = month, tz
+			return budgets, nil
+		},
+	}
+	srv := NewServer(store, &models.Config{})
+	srv.now = func() time.Time { return time.Date(2024, 4, 15, 12, 0, 0, 0, time.UTC) }
+
+	get := func(query string) (*models.ForecastResponse, int) {
+		w := serve(srv, http.MethodGet, "/api/analytics/forecast"+query, "")
+		if w.Code != http.StatusOK {
+			return nil, w.Code
+		}
+		var resp models.ForecastResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return &resp, w.Code
+	}
+
+	t.Run("current month by default", func(t *testing.T) {
+		resp, code := get("")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2024-04", resp.Month)
+		assert.Equal(t, "naive_linear", resp.Method)
+		assert.Equal(t, 15, resp.DaysElapsed)
+		assert.Equal(t, "900", resp.Projected.String())
+		assert.Nil(t, resp.Budget, "no budgets, no remaining")
+		assert.Nil(t, resp.Remaining)
+		assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), gotFrom)
+		assert.Equal(t, srv.now(), gotTo)
+	})
+
+	t.Run("remaining budget", func(t *testing.T) {
+		// Of the 450 spent, only the 120 on food is budgeted.
+		budgets = []models.BudgetStatus{
+			{Category: "Food", Month: "2024-04", Limit: decimal.RequireFromString("300.00"), Spent: decimal.RequireFromString("120.00")},
+		}
+		defer func() { budgets = []models.BudgetStatus{} }()
+
+		resp, code := get("?month=2024-04")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2024-04", gotMonth)
+		assert.Equal(t, "UTC", gotTZ)
+		require.NotNil(t, resp.Budget)
+		assert.Equal(t, "300", resp.Budget.String())
+		assert.Equal(t, "180", resp.Remaining.String(), "unbudgeted expenses don't count against the budget")
+	})
+
+	t.Run("past month counts every day", func(t *testing.T) {
+		resp, code := get("?month=2024-03")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 31, resp.DaysElapsed)
+		assert.Equal(t, "450", resp.Projected.String())
+		assert.Equal(t, time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC), gotTo)
+	})
+
+	t.Run("invalid months", func(t *testing.T) {
+		for _, query := range []string{"?month=2024-3", "?month=march", "?month=2024-05"} {
+			_, code := get(query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readyTimeout = 2 * time.Second
+
+// Version is the application version GET /api/version reports. Release
+// builds set it with -ldflags "-X L3_6/internal/server.Version=<version>".
+var Version = "dev"
+
+// health reports liveness: the process is up and serving HTTP.
+func (s *Server) health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// SetStartupSchemaVersion records the schema version storage.InitDB left the
+// database at, which /ready reports next to the current one.
+func (s *Server) SetStartupSchemaVersion(version uint) {
+	s.startupSchemaVersion = version
+}
+
+// ready reports readiness: the database is reachable and its schema isn't
+// dirty. The schema is read on every call, since a migration run by another
+// instance can fail after this one started; a dirty schema fails the check
+// so traffic moves away until it is repaired.
+func (s *Server) ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	// The causes are only logged: the probe is public and pgx errors name the
+	// database host and user.
+	if err := s.storage.Ping(ctx); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "database unreachable"})
+		return
+	}
+
+	schemaVersion, dirty, err := s.storage.SchemaVersion(ctx)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "schema check failed"})
+		return
+	}
+	body := gin.H{"status": "ready", "schema_version": schemaVersion, "dirty": dirty}
+	if s.startupSchemaVersion != 0 {
+		body["startup_schema_version"] = s.startupSchemaVersion
+	}
+	if dirty {
+		body["status"] = "unavailable"
+		body["error"] = fmt.Sprintf("schema version %d is dirty; fix the failed migration", schemaVersion)
+		c.JSON(http.StatusServiceUnavailable, body)
+		return
+	}
+
+	c.JSON(http.StatusOK, body)
+}
+
+// version reports the application version and the schema version recorded
+// in the database. A dirty schema is reported rather than failed, so
+// operators can spot a migration that broke part-way.
+//
+// @Summary Application and schema version
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.VersionInfo
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /version [get]
+func (s *Server) version(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	schemaVersion, dirty, err := s.storage.SchemaVersion(ctx)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.VersionInfo{Version: Version, SchemaVersion: schemaVersion, Dirty: dirty})
+}
+
+// poolStats reports the database connection pool statistics.
+//
+// @Summary Database pool statistics
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.PoolStats
+// @Failure 403 {object} errorResponse
+// @Security BearerAuth || AdminKey
+// @Router /admin/pool-stats [get]
+func (s *Server) poolStats(c *gin.Context) {
+	stat := s.storage.PoolStat()
+	c.JSON(http.StatusOK, models.PoolStats{
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		TotalConns:           stat.TotalConns(),
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDurationMs:    stat.AcquireDuration().Milliseconds(),
+	})
+}
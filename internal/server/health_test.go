@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestPoolStats_RequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Server.AdminKey = "secret"
+	srv := NewServer(nil, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/pool-stats", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	srv := NewServer(&mockStore{schemaVersion: func() (uint, bool, error) { return 16, true, nil }}, &models.Config{})
+	w := serve(srv, http.MethodGet, "/api/version", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"version":"dev","schema_version":16,"dirty":true}`, w.Body.String())
+
+	srv = NewServer(&mockStore{schemaVersion: func() (uint, bool, error) { return 0, false, errors.New("boom") }}, &models.Config{})
+	assert.Equal(t, http.StatusInternalServerError, serve(srv, http.MethodGet, "/api/version", "").Code)
+}
+
+func TestReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var pingErr error
+	dirty := false
+	store := &mockStore{
+		ping:          func() error { return pingErr },
+		schemaVersion: func() (uint, bool, error) { return 17, dirty, nil },
+	}
+	srv := NewServer(store, &models.Config{})
+	srv.SetStartupSchemaVersion(16)
+
+	w := serve(srv, http.MethodGet, "/ready", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ready","schema_version":17,"dirty":false,"startup_schema_version":16}`, w.Body.String())
+
+	// A migration that failed after startup takes the instance out of rotation.
+	dirty = true
+	w = serve(srv, http.MethodGet, "/ready", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"dirty":true`)
+	assert.Contains(t, w.Body.String(), "schema version 17 is dirty")
+
+	pingErr = errors.New(`failed to connect to host=db.internal user=sales database=sales: connection refused`)
+	w = serve(srv, http.MethodGet, "/ready", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"unavailable","error":"database unreachable"}`, w.Body.String(), "connection details stay in the log")
+
+	srv = NewServer(&mockStore{
+		ping:          func() error { return nil },
+		schemaVersion: func() (uint, bool, error) { return 0, false, errors.New("no schema version recorded") },
+	}, &models.Config{})
+	w = serve(srv, http.MethodGet, "/ready", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"unavailable","error":"schema check failed"}`, w.Body.String())
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+var importColumns = []string{"type", "amount", "date", "category"}
+
+// @Summary Import sales from CSV
+// @Tags import-export
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV file"
+// @Success 200 {object} models.ImportResult
+// @Failure 400 {object} errorResponse
+// @Failure 413 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /import [post]
+func (s *Server) importSales(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload exceeds %d bytes", tooLarge.Limit)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing CSV file in form field \"file\""})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	sales, rowErrors, err := s.parseImportCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusBadRequest, models.ImportResult{Errors: rowErrors})
+		return
+	}
+	if len(sales) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV contains no rows"})
+		return
+	}
+
+	if err := s.storage.CreateSalesBatch(sales); err != nil {
+		respondStorageError(c, err)
+		return
+	}
+
+	for _, sale := range sales {
+		s.salesChanged("sale.created", sale)
+	}
+	c.JSON(http.StatusCreated, models.ImportResult{Imported: len(sales), Errors: []models.ImportError{}})
+}
+
+// parseImportCSV reads a CSV with a header naming the import columns (in any
+// order) and validates every row the same way as the JSON endpoints. Per-row
+// problems are collected with their line numbers; a non-nil error means the
+// file as a whole is unusable.
+func (s *Server) parseImportCSV(r io.Reader) ([]models.Sale, []models.ImportError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, column := range importColumns {
+		if _, ok := index[column]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing column %q", column)
+		}
+	}
+
+	var sales []models.Sale
+	var rowErrors []models.ImportError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, models.ImportError{Line: parseErr.Line, Error: parseErr.Err.Error()})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		sale, err := s.saleFromRecord(record, index)
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportError{Line: line, Error: err.Error()})
+			continue
+		}
+		sales = append(sales, sale)
+	}
+
+	return sales, rowErrors, nil
+}
+
+func (s *Server) saleFromRecord(record []string, index map[string]int) (models.Sale, error) {
+	field := func(name string) string {
+		if i := index[name]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var sale models.Sale
+	var err error
+	sale.Type = field("type")
+	sale.Category = field("category")
+
+	if sale.Amount, err = decimal.NewFromString(field("amount")); err != nil {
+		return sale, fmt.Errorf("invalid amount %q", field("amount"))
+	}
+	if sale.Date, err = time.Parse(time.RFC3339, field("date")); err != nil {
+		return sale, fmt.Errorf("invalid date %q: expected RFC3339", field("date"))
+	}
+	if err := s.normalizeSale(&sale); err != nil {
+		return sale, err
+	}
+
+	return sale, nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	srv := &Server{cfg: &models.Config{}}
+
+	t.Run("valid rows in any column order", func(t *testing.T) {
+		data := "category,date,amount,type\n" +
+			"Food,2024-01-15T10:30:00Z,12.50,Expense\n" +
+			"Salary,2024-01-31T09:00:00Z,1000,income\n"
+
+		sales, rowErrors, err := srv.parseImportCSV(strings.NewReader(data))
+		require.NoError(t, err)
+		assert.Empty(t, rowErrors)
+		require.Len(t, sales, 2)
+		assert.Equal(t, "expense", sales[0].Type)
+		assert.Equal(t, "12.5", sales[0].Amount.String())
+		assert.Equal(t, "Salary", sales[1].Category)
+	})
+
+	t.Run("invalid rows reported by line", func(t *testing.T) {
+		data := "type,amount,date,category\n" +
+			"expense,12.50,2024-01-15T10:30:00Z,Food\n" +
+			"expense,abc,2024-01-15T10:30:00Z,Food\n" +
+			"transfer,5,2024-01-15T10:30:00Z,Food\n" +
+			"expense,5,15/01/2024,Food\n"
+
+		sales, rowErrors, err := srv.parseImportCSV(strings.NewReader(data))
+		require.NoError(t, err)
+		assert.Len(t, sales, 1)
+		require.Len(t, rowErrors, 3)
+		assert.Equal(t, 3, rowErrors[0].Line)
+		assert.Equal(t, 4, rowErrors[1].Line)
+		assert.Equal(t, 5, rowErrors[2].Line)
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		_, _, err := srv.parseImportCSV(strings.NewReader("type,amount,date\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestImportSales(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upload := func(srv *Server, content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("file", "sales.csv")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/api/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("invalid rows import nothing", func(t *testing.T) {
+		srv := NewServer(nil, &models.Config{})
+		w := upload(srv, "type,amount,date,category\nexpense,-1,2024-01-15T10:30:00Z,Food\n")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var result models.ImportResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, 0, result.Imported)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, 2, result.Errors[0].Line)
+	})
+
+	t.Run("upload over limit", func(t *testing.T) {
+		cfg := &models.Config{}
+		cfg.Server.MaxImportBytes = 64
+		srv := NewServer(nil, cfg)
+		w := upload(srv, "type,amount,date,category\n"+strings.Repeat("expense,1,2024-01-15T10:30:00Z,Food\n", 10))
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Check data integrity
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.IntegrityReport
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth || AdminKey
+// @Router /admin/integrity [get]
+func (s *Server) checkIntegrity(c *gin.Context) {
+	report, err := s.storage.CheckIntegrity()
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the Prometheus collectors for one server. Each server gets
+// its own registry so several can coexist, e.g. in tests.
+type metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+func (s *Server) newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if s.storage != nil {
+		m.registry.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "db_pool_acquired_conns",
+				Help: "Connections currently in use from the database pool.",
+			}, func() float64 { return float64(s.storage.PoolStat().AcquiredConns()) }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "db_pool_total_conns",
+				Help: "Connections currently open in the database pool.",
+			}, func() float64 { return float64(s.storage.PoolStat().TotalConns()) }),
+		)
+	}
+
+	return m
+}
+
+// instrument records the count and latency of every request. Routes are
+// labelled by their pattern (e.g. /api/items/:id) to keep cardinality bounded.
+func (m *metrics) instrument(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+
+	m.requests.WithLabelValues(c.Request.Method, route, status).Inc()
+	m.latency.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
+
+func (m *metrics) handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Auth.JWTSecret = "secret"
+	srv := NewServer(nil, cfg)
+	srv.router.GET("/panic", func(*gin.Context) { panic("boom") })
+
+	for _, path := range []string{"/health", "/api/items/abc/unknown", "/panic"} {
+		srv.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// /metrics stays reachable even with API auth enabled.
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/health",status="200"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/health",status="200"`)
+	assert.Contains(t, body, `route="unmatched",status="404"`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/panic",status="500"} 1`, "panics are counted")
+}
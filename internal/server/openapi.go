@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+
+	"L3_6/docs"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// countResponse is the body of GET /items/count.
+type countResponse struct {
+	Count int64 `json:"count"`
+}
+
+// validationResponse is the body of a failed POST /items/validate.
+type validationResponse struct {
+	Valid  bool          `json:"valid"`
+	Errors []saleProblem `json:"errors"`
+}
+
+// registerDocs serves the generated OpenAPI spec as raw JSON and through
+// Swagger UI, with the spec's base path adjusted to where the API is mounted.
+// Regenerate the spec with `make docs` after changing an annotation.
+func registerDocs(r gin.IRouter, basePath string) {
+	info := *docs.SwaggerInfo
+	info.BasePath = basePath + info.BasePath
+	spec := []byte(info.ReadDoc())
+
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+	})
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(basePath+"/openapi.json")))
+}
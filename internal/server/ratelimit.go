@@ -0,0 +1,91 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTTL is how long a client IP may stay silent before its bucket is
+// dropped. A dropped client simply starts again with a full bucket.
+const limiterIdleTTL = 10 * time.Minute
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipLimiter keeps one token bucket per client IP.
+type ipLimiter struct {
+	mu        sync.Mutex
+	visitors  map[string]*visitor
+	limit     rate.Limit
+	burst     int
+	lastSweep time.Time
+}
+
+func newIPLimiter(rps float64, burst int) *ipLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &ipLimiter{
+		visitors: make(map[string]*visitor),
+		limit:    rate.Limit(rps),
+		burst:    burst,
+	}
+}
+
+// allow takes a token for ip. When none is available it reports how long the
+// client should wait before retrying.
+func (l *ipLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= limiterIdleTTL {
+		l.evictIdle(now)
+		l.lastSweep = now
+	}
+
+	v, ok := l.visitors[ip]
+	if !ok {
+		v = &visitor{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.visitors[ip] = v
+	}
+	v.lastSeen = now
+
+	r := v.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+func (l *ipLimiter) evictIdle(now time.Time) {
+	for ip, v := range l.visitors {
+		if now.Sub(v.lastSeen) >= limiterIdleTTL {
+			delete(l.visitors, ip)
+		}
+	}
+}
+
+// rateLimit rejects clients that exceed their per-IP rate with a 429.
+func (s *Server) rateLimit(c *gin.Context) {
+	if s.limiter == nil {
+		c.Next()
+		return
+	}
+
+	ok, wait := s.limiter.allow(c.ClientIP(), s.now())
+	if !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+		return
+	}
+	c.Next()
+}
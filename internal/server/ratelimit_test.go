@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	l := newIPLimiter(1, 2)
+
+	t.Run("burst then throttle per IP", func(t *testing.T) {
+		ok, _ := l.allow("10.0.0.1", now)
+		assert.True(t, ok)
+		ok, _ = l.allow("10.0.0.1", now)
+		assert.True(t, ok)
+
+		ok, wait := l.allow("10.0.0.1", now)
+		assert.False(t, ok)
+		assert.Equal(t, time.Second, wait)
+
+		// Another client has its own bucket.
+		ok, _ = l.allow("10.0.0.2", now)
+		assert.True(t, ok)
+
+		// Tokens refill over time.
+		ok, _ = l.allow("10.0.0.1", now.Add(time.Second))
+		assert.True(t, ok)
+	})
+
+	t.Run("idle IPs are evicted", func(t *testing.T) {
+		l.allow("10.0.0.3", now.Add(limiterIdleTTL+2*time.Second))
+		assert.Len(t, l.visitors, 1)
+		assert.Contains(t, l.visitors, "10.0.0.3")
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Server.RateLimit = 1
+	cfg.Server.RateBurst = 1
+	srv := NewServer(nil, cfg)
+	srv.now = func() time.Time { return time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC) }
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/analytics/timeseries", nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get().Code)
+	w := get()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimit_ForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	get := func(srv *Server, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/analytics/timeseries", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w.Code
+	}
+	newServer := func(proxies ...string) *Server {
+		cfg := &models.Config{}
+		cfg.Server.RateLimit = 1
+		cfg.Server.RateBurst = 1
+		cfg.Server.TrustedProxies = proxies
+		srv := NewServer(nil, cfg)
+		srv.now = func() time.Time { return time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC) }
+		return srv
+	}
+
+	// Without trusted proxies a spoofed header doesn't buy a fresh bucket.
+	srv := newServer()
+	assert.Equal(t, http.StatusBadRequest, get(srv, "203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, get(srv, "203.0.113.2"))
+
+	// Behind a trusted proxy (httptest's 192.0.2.1) each client is limited
+	// on its own.
+	srv = newServer("192.0.2.1")
+	assert.Equal(t, http.StatusBadRequest, get(srv, "203.0.113.1"))
+	assert.Equal(t, http.StatusBadRequest, get(srv, "203.0.113.2"))
+	assert.Equal(t, http.StatusTooManyRequests, get(srv, "203.0.113.1"))
+}
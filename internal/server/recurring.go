@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"L3_6/internal/storage"
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindRecurringRule reads a recurring rule from the body and validates it
+// like a sale dated at its next occurrence. On invalid input it writes a 400
+// response and returns ok=false.
+func (s *Server) bindRecurringRule(c *gin.Context) (models.RecurringRule, bool) {
+	var rule models.RecurringRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		respondBindError(c, err)
+		return rule, false
+	}
+
+	sale := models.Sale{Type: rule.Type, Amount: rule.Amount, Date: rule.NextDate, Category: rule.Category}
+	if err := s.normalizeSale(&sale); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return rule, false
+	}
+	rule.Type, rule.Category = sale.Type, sale.Category
+
+	if !storage.RecurringIntervals[rule.Interval] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid interval %q: must be daily, weekly, monthly or yearly", rule.Interval)})
+		return rule, false
+	}
+	return rule, true
+}
+
+// @Summary List recurring rules
+// @Tags recurring
+// @Produce json
+// @Success 200 {array} models.RecurringRule
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /recurring [get]
+func (s *Server) listRecurringRules(c *gin.Context) {
+	rules, err := s.storage.ListRecurringRules()
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// @Summary Get a recurring rule
+// @Tags recurring
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} models.RecurringRule
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /recurring/{id} [get]
+func (s *Server) getRecurringRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	rule, err := s.storage.GetRecurringRule(id)
+	if err != nil {
+		respondRecurringRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// @Summary Create a recurring rule
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Param rule body models.RecurringRule true "Rule"
+// @Success 201 {object} models.RecurringRule
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /recurring [post]
+func (s *Server) createRecurringRule(c *gin.Context) {
+	rule, ok := s.bindRecurringRule(c)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.CreateRecurringRule(&rule); err != nil {
+		respondRecurringRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// @Summary Replace a recurring rule
+// @Tags recurring
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param rule body models.RecurringRule true "Rule"
+// @Success 200 {object} models.RecurringRule
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /recurring/{id} [put]
+func (s *Server) updateRecurringRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	rule, ok := s.bindRecurringRule(c)
+	if !ok {
+		return
+	}
+	rule.ID = id
+
+	if err := s.storage.UpdateRecurringRule(&rule); err != nil {
+		respondRecurringRuleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// @Summary Delete a recurring rule
+// @Tags recurring
+// @Produce json
+// @Param id path int true "ID"
+// @Success 204
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /recurring/{id} [delete]
+func (s *Server) deleteRecurringRule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := s.storage.DeleteRecurringRule(id); err != nil {
+		respondRecurringRuleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondRecurringRuleError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrRecurringRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring rule not found"})
+		return
+	}
+	respondStorageError(c, err)
+}
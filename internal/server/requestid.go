@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+)
+
+// requestID tags each request with a correlation ID, taken from the incoming
+// X-Request-ID header or generated, and echoes it on the response.
+func requestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if id == "" || len(id) > 128 {
+		id = uuid.NewString()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(requestIDHeader, id)
+	c.Next()
+}
+
+// respondInternalError writes a generic 500 carrying the request ID, so the
+// failure can be matched to its log entry; the error itself is only logged,
+// as it may reveal queries or hosts. A failure caused by the request running
+// out of time (see requestTimeout) is answered with a 503 instead.
+func respondInternalError(c *gin.Context, err error) {
+	c.Error(err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		respondTimeout(c)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": internalErrorMessage, "request_id": c.GetString(requestIDKey)})
+}
+
+const internalErrorMessage = "Internal server error"
+
+// recoverPanic reports panics the same way as other internal errors.
+func recoverPanic(c *gin.Context, _ any) {
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": internalErrorMessage, "request_id": c.GetString(requestIDKey)})
+}
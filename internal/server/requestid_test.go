@@ -0,0 +1,54 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(requestID, gin.CustomRecovery(recoverPanic))
+	r.GET("/fail", func(c *gin.Context) { respondInternalError(c, errors.New("storage.GetSales: boom")) })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	serve := func(path, incoming string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if incoming != "" {
+			req.Header.Set(requestIDHeader, incoming)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("incoming ID is echoed in header and 500 body", func(t *testing.T) {
+		w := serve("/fail", "trace-42")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "trace-42", w.Header().Get(requestIDHeader))
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "trace-42", body["request_id"])
+		assert.Equal(t, "Internal server error", body["error"], "the cause is logged, not returned")
+	})
+
+	t.Run("ID is generated when absent", func(t *testing.T) {
+		w := serve("/panic", "")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		id := w.Header().Get(requestIDHeader)
+		assert.Len(t, id, 36)
+
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, id, body["request_id"])
+	})
+}
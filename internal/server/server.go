@@ -1,76 +1,805 @@
 package server
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"L3_6/internal/storage"
+	"L3_6/internal/webhook"
 	"L3_6/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	storage *storage.Storage
-	router  *gin.Engine
+	storage  SaleStore
+	router   *gin.Engine
+	cfg      *models.Config
+	webhooks *webhook.Dispatcher
+	logger   *slog.Logger
+	metrics  *metrics
+	location *time.Location
+	now      func() time.Time
+	// limiter throttles /api per client IP; nil disables rate limiting.
+	limiter *ipLimiter
+	// analyticsSlots bounds concurrent analytics queries; nil means unlimited.
+	analyticsSlots chan struct{}
+	// analyticsCache holds recent analytics results; nil disables caching.
+	analyticsCache *analyticsCache
+	// conns tracks the connections of the server started by Run.
+	conns connCounter
+	// startupSchemaVersion is the schema version the database was at when
+	// the process started; zero when unknown.
+	startupSchemaVersion uint
 }
 
-func NewServer(storage *storage.Storage) *Server {
-	server := &Server{storage: storage}
+// NewServer wires the routes around store. cmd/main passes a
+// *storage.Storage; tests may pass a fake SaleStore or nil for routes that
+// never reach the store. Requests are logged through slog's default logger,
+// which cmd/main configures before calling it.
+func NewServer(store SaleStore, cfg *models.Config) *Server {
+	server := &Server{
+		storage:  store,
+		cfg:      cfg,
+		webhooks: webhook.NewDispatcher(store, cfg),
+		logger:   slog.Default(),
+		location: time.UTC,
+		now:      time.Now,
+	}
+	if loc, err := time.LoadLocation(cfg.Analytics.Timezone); err != nil {
+		server.logger.Warn("invalid analytics timezone, using UTC", "timezone", cfg.Analytics.Timezone, "err", err)
+	} else {
+		server.location = loc
+	}
+	if cfg.Server.RateLimit > 0 {
+		server.limiter = newIPLimiter(cfg.Server.RateLimit, cfg.Server.RateBurst)
+	}
+	if n := cfg.Analytics.MaxConcurrent; n > 0 {
+		server.analyticsSlots = make(chan struct{}, n)
+	}
+	if ttl := cfg.Analytics.CacheTTL; ttl > 0 {
+		server.analyticsCache = newAnalyticsCache(ttl, func() time.Time { return server.now() })
+	}
+	server.metrics = server.newMetrics()
 	server.setupRouter()
 	return server
 }
 
 func (s *Server) setupRouter() {
-	r := gin.Default()
+	// Everything is mounted under the configured base path
+	basePath := strings.TrimSuffix(s.cfg.Server.BasePath, "/")
+
+	// Uploads get their own, larger body limits
+	bodyLimits := map[string]int64{
+		basePath + "/api/import":                s.cfg.Server.MaxImportBytes,
+		basePath + "/api/items/:id/attachments": attachmentBodyLimit(s.cfg.Server.MaxAttachmentBytes),
+	}
+
+	// Streaming downloads are exempt from the request and write timeouts
+	longRunning := map[string]bool{
+		basePath + "/api/export":                     true,
+		basePath + "/api/items/:id/attachments/:aid": true,
+	}
+
+	if mode := s.cfg.Server.GinMode; mode != "" {
+		gin.SetMode(mode)
+	}
+	r := gin.New()
+	// Only configured proxies may speak for clients, so ClientIP (and the
+	// rate limiter keyed on it) can't be steered with X-Forwarded-For
+	if err := r.SetTrustedProxies(s.cfg.Server.TrustedProxies); err != nil {
+		s.logger.Warn("invalid trusted proxies, trusting none", "proxies", s.cfg.Server.TrustedProxies, "err", err)
+		_ = r.SetTrustedProxies(nil)
+	}
+	// instrument wraps the recovery so requests that panic are counted as 500s.
+	r.Use(requestID, requestLogger(s.logger), s.metrics.instrument, gin.CustomRecovery(recoverPanic), cors(s.cfg),
+		compress(s.cfg), limitBody(s.cfg.Server.MaxBodyBytes, bodyLimits), requestTimeout(s.cfg.Server.RequestTimeout, longRunning))
+	root := r.Group(basePath)
 
 	// Serve static files
-	r.Static("/web", "./web")
+	root.Static("/web", "./web")
+
+	// Probes stay outside /api so API middleware never applies to them
+	root.GET("/health", s.health)
+	root.GET("/ready", s.ready)
+	root.GET("/metrics", s.metrics.handler())
+
+	// API docs: Swagger UI and the raw OpenAPI spec
+	registerDocs(root, basePath)
+
+	// Login must stay reachable without a token
+	root.POST("/api/login", s.rateLimit, s.login)
 
 	// API routes
-	api := r.Group("/api")
+	api := root.Group("/api", s.rateLimit, s.requireAuth)
 	{
 		api.POST("/items", s.createSale)
+		api.POST("/items/batch", s.createSalesBatch)
+		api.POST("/items/validate", s.validateSales)
 		api.GET("/items", s.getSales)
+		api.GET("/items/count", s.countSales)
+		api.GET("/items/incomplete", s.getIncompleteSales)
+		api.GET("/items/search", s.searchSales)
+		api.GET("/items/top", s.getTopSales)
+		api.GET("/items/recent", s.getRecentSales)
+		api.PUT("/items/recategorize", s.recategorizeSales)
+		api.PUT("/items/bulk", s.bulkUpdateSales)
+		api.GET("/items/:id", s.getSale)
 		api.PUT("/items/:id", s.updateSale)
+		api.PATCH("/items/:id", s.patchSale)
+		api.DELETE("/items", s.deleteSalesInRange)
+		api.DELETE("/items/all", s.deleteAllSales)
 		api.DELETE("/items/:id", s.deleteSale)
-		api.GET("/analytics", s.getAnalytics)
-		api.GET("/export", s.exportCSV)
+		api.POST("/items/:id/restore", s.restoreSale)
+		api.PATCH("/items/:id/lock", s.lockSale)
+		api.GET("/items/:id/history", s.getSaleHistory)
+		api.POST("/items/:id/attachments", s.uploadAttachment)
+		api.GET("/items/:id/attachments/:aid", s.getAttachment)
+
+		api.GET("/categories", s.listCategories)
+		api.GET("/categories/usage", s.listCategoryUsage)
+		api.POST("/categories", s.createCategory)
+		api.GET("/categories/:id", s.getCategory)
+		api.PUT("/categories/:id", s.renameCategory)
+		api.DELETE("/categories/:id", s.deleteCategory)
+
+		api.GET("/periods", s.getPeriods)
+		api.GET("/version", s.version)
+
+		api.GET("/budgets", s.listBudgets)
+		api.POST("/budgets", s.createBudget)
+		api.GET("/budgets/status", s.getBudgetStatus)
+		api.GET("/budgets/:id", s.getBudget)
+		api.PUT("/budgets/:id", s.updateBudget)
+		api.DELETE("/budgets/:id", s.deleteBudget)
+
+		api.GET("/recurring", s.listRecurringRules)
+		api.POST("/recurring", s.createRecurringRule)
+		api.GET("/recurring/:id", s.getRecurringRule)
+		api.PUT("/recurring/:id", s.updateRecurringRule)
+		api.DELETE("/recurring/:id", s.deleteRecurringRule)
+
+		analytics := api.Group("/analytics", s.limitAnalytics)
+		analytics.GET("", s.getAnalytics)
+		analytics.GET("/pace", s.getPace)
+		analytics.GET("/forecast", s.getForecast)
+		analytics.GET("/timeseries", s.getTimeSeries)
+		analytics.GET("/networth", s.getNetWorth)
+		analytics.GET("/distribution", s.getDistribution)
+		analytics.GET("/streak", s.getStreak)
+		analytics.GET("/top-categories", s.getTopCategories)
+		analytics.GET("/daily", s.getDailyExpenses)
+		analytics.GET("/tags", s.getTagTotals)
+		analytics.GET("/payment-methods", s.getPaymentMethodTotals)
+		api.GET("/dashboard", s.limitAnalytics, s.getDashboard)
+
+		api.GET("/export", s.exportSales)
+		api.POST("/import", s.importSales)
+
+		admin := api.Group("/admin", s.requireAdmin)
+		admin.GET("/webhook-deliveries", s.getWebhookDeliveries)
+		admin.POST("/webhook-deliveries/:id/redeliver", s.redeliverWebhook)
+		admin.GET("/integrity", s.checkIntegrity)
+		admin.GET("/pool-stats", s.poolStats)
 	}
 
 	s.router = r
 }
 
-func (s *Server) Run(port string) error {
-	return s.router.Run(":" + port)
+// Run serves on port until ctx is done, then shuts down gracefully (see
+// shutdown), or until the listener fails. It speaks HTTPS when a TLS
+// certificate and key are configured and plain HTTP otherwise.
+func (s *Server) Run(ctx context.Context, port string) error {
+	srv := s.httpServer(":" + port)
+
+	errc := make(chan error, 1)
+	go func() {
+		if s.TLSEnabled() {
+			errc <- srv.ListenAndServeTLS(s.cfg.Server.TLSCertFile, s.cfg.Server.TLSKeyFile)
+			return
+		}
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return s.shutdown(srv)
+	}
+}
+
+// TLSEnabled reports whether Run serves HTTPS.
+func (s *Server) TLSEnabled() bool {
+	return s.cfg.Server.TLSCertFile != "" && s.cfg.Server.TLSKeyFile != ""
+}
+
+// httpServer wraps the router in an http.Server with the configured timeouts,
+// so slow clients can't hold connections open indefinitely.
+func (s *Server) httpServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s.router,
+		ReadHeaderTimeout: s.cfg.Server.ReadTimeout,
+		ReadTimeout:       s.cfg.Server.ReadTimeout,
+		WriteTimeout:      s.cfg.Server.WriteTimeout,
+		IdleTimeout:       s.cfg.Server.IdleTimeout,
+		ConnState:         s.conns.track,
+	}
+}
+
+// salesChanged reports a change to sales: cached analytics are dropped and
+// webhook subscribers are notified of event.
+func (s *Server) salesChanged(event string, payload any) {
+	if s.analyticsCache != nil {
+		s.analyticsCache.invalidate()
+	}
+	s.webhooks.Notify(event, payload)
 }
 
+// Notify reports a change to sales made outside the API, such as by the
+// recurring scheduler, as salesChanged does.
+func (s *Server) Notify(event string, payload any) {
+	s.salesChanged(event, payload)
+}
+
+// @Summary Create a sale
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param sale body models.Sale true "Sale"
+// @Param Idempotency-Key header string false "Deduplicates retries"
+// @Param allow_duplicate query bool false "Create even if it looks like a duplicate"
+// @Success 201 {object} models.Sale
+// @Header 201 {string} Location "URL of the created sale"
+// @Failure 400 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items [post]
 func (s *Server) createSale(c *gin.Context) {
 	var sale models.Sale
 	if err := c.ShouldBindJSON(&sale); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+
+	if err := s.normalizeSale(&sale); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Retries carrying an Idempotency-Key are already deduplicated by key.
+	key := c.GetHeader("Idempotency-Key")
+	if key != "" {
+		s.createSaleIdempotent(c, &sale, key)
+		return
+	}
+
+	// Not ?force, which overrides sale locks and needs the admin key.
+	if window := s.cfg.Sales.DuplicateWindow; window > 0 && c.Query("allow_duplicate") != "true" {
+		duplicate, err := s.storage.FindPotentialDuplicate(&sale, window)
+		if err != nil {
+			respondInternalError(c, err)
+			return
+		}
+		if duplicate != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     "Looks like a duplicate of an existing sale; retry with ?allow_duplicate=true to create it anyway",
+				"duplicate": duplicate,
+			})
+			return
+		}
+	}
+
 	if err := s.storage.CreateSale(&sale); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondStorageError(c, err)
 		return
 	}
 
+	s.salesChanged("sale.created", sale)
+	c.Header("Location", s.saleURL(sale.ID))
 	c.JSON(http.StatusCreated, sale)
 }
 
+// createSaleIdempotent creates the sale at most once per Idempotency-Key. A
+// repeated key answers with the originally created sale and marks the reply
+// with Idempotent-Replayed.
+func (s *Server) createSaleIdempotent(c *gin.Context, sale *models.Sale, key string) {
+	if len(key) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return
+	}
+
+	window := s.cfg.Server.IdempotencyWindow
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	replayed, err := s.storage.CreateSaleIdempotent(sale, key, window)
+	if err != nil {
+		respondStorageError(c, err)
+		return
+	}
+
+	if replayed {
+		c.Header("Idempotent-Replayed", "true")
+	} else {
+		s.salesChanged("sale.created", *sale)
+	}
+	c.Header("Location", s.saleURL(sale.ID))
+	c.JSON(http.StatusCreated, sale)
+}
+
+// createSalesBatch stores an array of sales. By default the batch is atomic:
+// one invalid or rejected sale fails the request and nothing is stored. With
+// ?mode=best-effort each sale is stored on its own; see
+// createSalesBestEffort.
+//
+// @Summary Create several sales at once
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param sales body []models.Sale true "Sales"
+// @Param mode query string false "All or nothing, or store every sale that can be" Enums(atomic, best-effort) default(atomic)
+// @Success 200 {object} batchResponse "best-effort mode"
+// @Success 201 {array} models.Sale
+// @Header 201 {string} Link "URL of each created sale, with rel=\"item\""
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/batch [post]
+func (s *Server) createSalesBatch(c *gin.Context) {
+	mode := c.DefaultQuery("mode", "atomic")
+	if mode != "atomic" && mode != "best-effort" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode: must be atomic or best-effort"})
+		return
+	}
+
+	var sales []models.Sale
+	if err := c.ShouldBindJSON(&sales); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if len(sales) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batch is empty"})
+		return
+	}
+	if mode == "best-effort" {
+		s.createSalesBestEffort(c, sales)
+		return
+	}
+
+	for i := range sales {
+		if err := s.normalizeSale(&sales[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "index": i})
+			return
+		}
+	}
+
+	if err := s.storage.CreateSalesBatch(sales); err != nil {
+		respondStorageError(c, err)
+		return
+	}
+
+	// A batch has no single Location, so each new sale gets a Link instead.
+	links := make([]string, len(sales))
+	for i, sale := range sales {
+		s.salesChanged("sale.created", sale)
+		links[i] = fmt.Sprintf(`<%s>; rel="item"`, s.saleURL(sale.ID))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+	c.JSON(http.StatusCreated, sales)
+}
+
+// batchResponse is the body of a best-effort batch create.
+type batchResponse struct {
+	Created int           `json:"created"`
+	Failed  int           `json:"failed"`
+	Results []batchResult `json:"results"`
+}
+
+// batchResult reports what happened to the sale at Index of the batch: its
+// ID if it was stored, otherwise why not.
+type batchResult struct {
+	Index      int    `json:"index"`
+	ID         int    `json:"id,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// createSalesBestEffort stores every valid sale of a batch independently and
+// answers 200 with a result per sale, in request order. Sales failing
+// validation are reported without reaching the database; database rejections
+// are reported like respondStorageError would, and other failures are logged
+// and reported generically.
+// Created sales get a Link each, as in an atomic batch.
+func (s *Server) createSalesBestEffort(c *gin.Context, sales []models.Sale) {
+	results := make([]batchResult, len(sales))
+	valid := make([]models.Sale, 0, len(sales))
+	indexes := make([]int, 0, len(sales))
+	for i := range sales {
+		results[i].Index = i
+		if err := s.normalizeSale(&sales[i]); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, sales[i])
+		indexes = append(indexes, i)
+	}
+
+	var errs []error
+	if len(valid) > 0 {
+		errs = s.storage.CreateSalesEach(valid)
+	}
+
+	var links []string
+	for j, err := range errs {
+		result := &results[indexes[j]]
+		if err != nil {
+			var constraintErr *storage.ConstraintError
+			switch {
+			case errors.As(err, &constraintErr) && errors.Is(err, storage.ErrCheckViolation):
+				result.Error, result.Constraint = "Value rejected by database constraint", constraintErr.Constraint
+			case errors.As(err, &constraintErr) && errors.Is(err, storage.ErrUniqueViolation):
+				result.Error, result.Constraint = "Duplicate value", constraintErr.Constraint
+			default:
+				c.Error(err)
+				result.Error = internalErrorMessage
+			}
+			continue
+		}
+		result.ID = valid[j].ID
+		s.salesChanged("sale.created", valid[j])
+		links = append(links, fmt.Sprintf(`<%s>; rel="item"`, s.saleURL(valid[j].ID)))
+	}
+
+	resp := batchResponse{Results: results}
+	for _, result := range results {
+		if result.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Created++
+		}
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// saleURL is the path of the sale with the given ID, including the base path.
+func (s *Server) saleURL(id int) string {
+	return strings.TrimSuffix(s.cfg.Server.BasePath, "/") + "/api/items/" + strconv.Itoa(id)
+}
+
+// @Summary Get a sale
+// @Tags sales
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id} [get]
+func (s *Server) getSale(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	sale, err := s.storage.GetSale(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrSaleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sale)
+}
+
+// @Summary List sales
+// @Tags sales
+// @Produce json
+// @Param from query string false "Earliest date (RFC3339)"
+// @Param to query string false "Latest date (RFC3339)"
+// @Param near query string false "Proximity filter as lat,lng,radiuskm"
+// @Param sort query string false "Sort column" Enums(date, amount, category, id) default(date)
+// @Param order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Param type query string false "Sale type" Enums(income, expense)
+// @Param category query string false "Category, ignoring case"
+// @Param method query string false "Payment method, ignoring case"
+// @Param uncategorized query bool false "Only sales in the default category"
+// @Param tag query []string false "Only sales carrying every given tag; repeat for several" collectionFormat(multi)
+// @Param balance query bool false "Include the running balance"
+// @Param after query string false "Cursor from a previous page's next_cursor"
+// @Param limit query int false "Page size, capped at 500" default(50)
+// @Success 200 {array} models.Sale
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, if any"
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items [get]
 func (s *Server) getSales(c *gin.Context) {
-	sales, err := s.storage.GetSales()
+	filter, ok := s.parseSaleFilter(c)
+	if !ok {
+		return
+	}
+	if balance := c.Query("balance"); balance != "" {
+		var err error
+		if filter.Balance, err = strconv.ParseBool(balance); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid balance flag"})
+			return
+		}
+	}
+	if !parsePage(c, &filter) {
+		return
+	}
+
+	// Fetch one extra sale to learn whether another page follows.
+	if filter.Limit > 0 {
+		filter.Limit++
+	}
+	sales, err := s.storage.GetSalesFiltered(filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, sales)
+	var next string
+	if filter.Limit > 0 && len(sales) == filter.Limit {
+		sales = sales[:len(sales)-1]
+		last := sales[len(sales)-1]
+		next = encodeCursor(models.SaleCursor{Date: last.Date, ID: last.ID})
+	}
+	s.respondSalesPage(c, sales, next)
+}
+
+// countSales reports how many sales match the list filters without
+// fetching them.
+//
+// @Summary Count sales
+// @Tags sales
+// @Produce json
+// @Param from query string false "Earliest date (RFC3339)"
+// @Param to query string false "Latest date (RFC3339)"
+// @Param type query string false "Sale type" Enums(income, expense)
+// @Param category query string false "Category, ignoring case"
+// @Param method query string false "Payment method, ignoring case"
+// @Param near query string false "Proximity filter as lat,lng,radiuskm"
+// @Param uncategorized query bool false "Only sales in the default category"
+// @Param tag query []string false "Only sales carrying every given tag; repeat for several" collectionFormat(multi)
+// @Success 200 {object} countResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/count [get]
+func (s *Server) countSales(c *gin.Context) {
+	filter, ok := s.parseSaleFilter(c)
+	if !ok {
+		return
+	}
+
+	count, err := s.storage.CountSales(filter)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: count})
+}
+
+// parsePage reads keyset pagination parameters: ?after resumes past a cursor
+// from a previous page and ?limit sets the page size. Either one turns
+// pagination on, with a default page size of 50 capped at 500. On invalid
+// input it writes a 400 response and returns false.
+func parsePage(c *gin.Context, filter *models.SaleFilter) bool {
+	after, limit := c.Query("after"), c.Query("limit")
+	if after == "" && limit == "" {
+		return true
+	}
+	if filter.Sort != "date" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pagination requires sort=date"})
+		return false
+	}
+
+	filter.Limit = defaultPageSize
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: must be a positive integer"})
+			return false
+		}
+		filter.Limit = min(n, maxPageSize)
+	}
+	if after != "" {
+		cursor, err := decodeCursor(after)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after: " + err.Error()})
+			return false
+		}
+		filter.After = cursor
+	}
+	return true
 }
 
+// @Summary Search sales by category or note
+// @Tags sales
+// @Produce json
+// @Param q query string true "Text to find in the category or note, ignoring case"
+// @Success 200 {array} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/search [get]
+func (s *Server) searchSales(c *gin.Context) {
+	term := strings.TrimSpace(c.Query("q"))
+	if term == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing search term q"})
+		return
+	}
+
+	sales, err := s.storage.SearchSales(term)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	s.respondSales(c, sales)
+}
+
+// @Summary List sales missing fields
+// @Tags sales
+// @Produce json
+// @Param require query string false "Comma-separated fields that must be set: category, location, note" default(category)
+// @Success 200 {array} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/incomplete [get]
+func (s *Server) getIncompleteSales(c *gin.Context) {
+	fields := strings.Split(c.DefaultQuery("require", "category"), ",")
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+		if _, ok := storage.IncompleteFields[fields[i]]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid require field %q", fields[i])})
+			return
+		}
+	}
+
+	sales, err := s.storage.GetIncompleteSales(fields, s.cfg.Sales.DefaultCategory)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	s.respondSales(c, sales)
+}
+
+const (
+	defaultTopSales = 10
+	maxTopSales     = 100
+
+	defaultRecentSales = 10
+	maxRecentSales     = 100
+)
+
+// getRecentSales lists the latest sales by date for "recent activity"
+// widgets: a single page with no filters or cursor. ?limit defaults to 10 and
+// is capped at 100.
+//
+// @Summary List the most recent sales
+// @Tags sales
+// @Produce json
+// @Param limit query int false "Maximum sales, capped at 100" default(10)
+// @Success 200 {array} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/recent [get]
+func (s *Server) getRecentSales(c *gin.Context) {
+	limit := defaultRecentSales
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: must be a positive integer"})
+			return
+		}
+		limit = min(n, maxRecentSales)
+	}
+
+	sales, err := s.storage.GetRecentSales(limit)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	s.respondSales(c, sales)
+}
+
+// getTopSales lists the largest individual sales in a range, for reviewing
+// unusual transactions; unlike top categories nothing is aggregated. Expenses
+// unless ?type=income; ?limit defaults to 10 and is capped at 100.
+//
+// @Summary List the largest sales
+// @Tags sales
+// @Produce json
+// @Param from query string false "Range start (RFC3339 or YYYY-MM-DD); defaults per analytics.default_range"
+// @Param to query string false "Range end (RFC3339 or YYYY-MM-DD, inclusive); defaults per analytics.default_range"
+// @Param tz query string false "IANA time zone for date-only bounds; defaults to analytics.timezone"
+// @Param type query string false "Sale type" Enums(income, expense) default(expense)
+// @Param limit query int false "Maximum sales, capped at 100" default(10)
+// @Success 200 {array} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/top [get]
+func (s *Server) getTopSales(c *gin.Context) {
+	loc, ok := s.parseTimezone(c)
+	if !ok {
+		return
+	}
+	from, to, ok := s.parseRange(c, loc)
+	if !ok {
+		return
+	}
+
+	saleType := c.DefaultQuery("type", "expense")
+	if !saleTypes[saleType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type"})
+		return
+	}
+
+	limit := defaultTopSales
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit: must be a positive integer"})
+			return
+		}
+		limit = min(n, maxTopSales)
+	}
+
+	sales, err := s.storage.GetTopSales(from, to, saleType, limit)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	s.respondSales(c, sales)
+}
+
+// updateSale replaces the sale at the path ID. The body may leave id out or
+// repeat the path ID; a different one is rejected rather than ignored, so a
+// client can't believe it updated another sale.
+//
+// @Summary Replace a sale
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param sale body models.Sale true "Sale"
+// @Param force query bool false "Override a sale lock (requires X-Admin-Key)"
+// @Success 200 {object} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id} [put]
 func (s *Server) updateSale(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -80,19 +809,206 @@ func (s *Server) updateSale(c *gin.Context) {
 
 	var sale models.Sale
 	if err := c.ShouldBindJSON(&sale); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+	if sale.ID != 0 && sale.ID != id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Body id %d does not match path id %d", sale.ID, id)})
+		return
+	}
+
+	if err := s.normalizeSale(&sale); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	force, ok := s.forceRequested(c)
+	if !ok {
 		return
 	}
 
 	sale.ID = id
-	if err := s.storage.UpdateSale(&sale); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := s.storage.UpdateSale(&sale, force); err != nil {
+		if errors.Is(err, storage.ErrSaleLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Sale is locked; unlock it before editing"})
+			return
+		}
+		if errors.Is(err, storage.ErrVersionConflict) {
+			respondVersionConflict(c)
+			return
+		}
+		respondStorageError(c, err)
+		return
+	}
+
+	s.salesChanged("sale.updated", sale)
+	c.JSON(http.StatusOK, sale)
+}
+
+// @Summary Update some fields of a sale
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param patch body models.SalePatch true "Fields to change"
+// @Param force query bool false "Override a sale lock (requires X-Admin-Key)"
+// @Success 200 {object} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id} [patch]
+func (s *Server) patchSale(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var patch models.SalePatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	force, ok := s.forceRequested(c)
+	if !ok {
+		return
+	}
+
+	existing, err := s.storage.GetSale(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrSaleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	if patch.Version != nil && *patch.Version != existing.Version {
+		respondVersionConflict(c)
+		return
+	}
+
+	// Validate the merged sale so a patch can't produce a row PUT would reject.
+	// Saving against the version just read keeps the validation from racing
+	// with concurrent edits.
+	merged := *existing
+	columns := applySalePatch(&merged, patch)
+	if len(columns) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+	if err := s.normalizeSale(&merged); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	sale, err := s.storage.PatchSale(id, saleColumnValues(&merged, columns), existing.Version, force)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSaleLocked):
+			c.JSON(http.StatusConflict, gin.H{"error": "Sale is locked; unlock it before editing"})
+		case errors.Is(err, storage.ErrVersionConflict):
+			respondVersionConflict(c)
+		case errors.Is(err, storage.ErrSaleNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+		default:
+			respondStorageError(c, err)
+		}
 		return
 	}
 
+	s.salesChanged("sale.updated", sale)
 	c.JSON(http.StatusOK, sale)
 }
 
+// applySalePatch copies the non-nil patch fields onto sale and returns the
+// names of the columns it touched.
+func applySalePatch(sale *models.Sale, patch models.SalePatch) []string {
+	var columns []string
+	if patch.Type != nil {
+		sale.Type = *patch.Type
+		columns = append(columns, "type")
+	}
+	if patch.Amount != nil {
+		sale.Amount = *patch.Amount
+		columns = append(columns, "amount")
+	}
+	if patch.Date != nil {
+		sale.Date = *patch.Date
+		columns = append(columns, "date")
+	}
+	if patch.Category != nil {
+		sale.Category = *patch.Category
+		columns = append(columns, "category")
+	}
+	if patch.Tags != nil {
+		sale.Tags = *patch.Tags
+		columns = append(columns, "tags")
+	}
+	if patch.Note != nil {
+		sale.Note = *patch.Note
+		columns = append(columns, "note")
+	}
+	if patch.PaymentMethod != nil {
+		sale.PaymentMethod = *patch.PaymentMethod
+		columns = append(columns, "payment_method")
+	}
+	if patch.Lat != nil {
+		sale.Lat = patch.Lat
+		columns = append(columns, "lat")
+	}
+	if patch.Lng != nil {
+		sale.Lng = patch.Lng
+		columns = append(columns, "lng")
+	}
+	return columns
+}
+
+// saleColumnValues returns the sale's values for the given columns.
+func saleColumnValues(sale *models.Sale, columns []string) map[string]any {
+	values := make(map[string]any, len(columns))
+	for _, column := range columns {
+		switch column {
+		case "type":
+			values[column] = sale.Type
+		case "amount":
+			values[column] = sale.Amount
+		case "date":
+			values[column] = sale.Date
+		case "category":
+			values[column] = sale.Category
+		case "tags":
+			values[column] = sale.Tags
+		case "note":
+			values[column] = sale.Note
+		case "payment_method":
+			values[column] = sale.PaymentMethod
+		case "lat":
+			values[column] = sale.Lat
+		case "lng":
+			values[column] = sale.Lng
+		}
+	}
+	return values
+}
+
+// @Summary Delete a sale
+// @Tags sales
+// @Produce json
+// @Param id path int true "ID"
+// @Param hard query bool false "Remove permanently instead of soft-deleting"
+// @Param force query bool false "Override a sale lock (requires X-Admin-Key)"
+// @Success 204
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id} [delete]
 func (s *Server) deleteSale(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -100,40 +1016,421 @@ func (s *Server) deleteSale(c *gin.Context) {
 		return
 	}
 
-	if err := s.storage.DeleteSale(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	force, ok := s.forceRequested(c)
+	if !ok {
 		return
 	}
 
+	// Deletes are soft (restorable) unless ?hard=true asks for removal.
+	deleteFn := s.storage.DeleteSale
+	if c.Query("hard") == "true" {
+		deleteFn = s.storage.HardDeleteSale
+	}
+
+	if err := deleteFn(id, force); err != nil {
+		if errors.Is(err, storage.ErrSaleLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Sale is locked; unlock it before deleting"})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	s.salesChanged("sale.deleted", gin.H{"id": id})
 	c.Status(http.StatusNoContent)
 }
 
-func (s *Server) getAnalytics(c *gin.Context) {
-	fromStr := c.Query("from")
-	toStr := c.Query("to")
-
-	from, err := time.Parse(time.RFC3339, fromStr)
+// deleteSalesInRange soft-deletes all sales dated between the required from
+// and to. As a guard against accidental mass deletion the request must also
+// carry ?confirm=true.
+//
+// @Summary Delete all sales in a date range
+// @Tags sales
+// @Produce json
+// @Param from query string true "Range start (RFC3339)"
+// @Param to query string true "Range end (RFC3339)"
+// @Param confirm query bool true "Must be true"
+// @Param force query bool false "Override a sale lock (requires X-Admin-Key)"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items [delete]
+func (s *Server) deleteSalesInRange(c *gin.Context) {
+	from, err := parseOptionalTime(c.Query("from"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
 		return
 	}
-
-	to, err := time.Parse(time.RFC3339, toStr)
+	to, err := parseOptionalTime(c.Query("to"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
 		return
 	}
+	if from == nil || to == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Both from and to are required for a bulk delete"})
+		return
+	}
+	if c.Query("confirm") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bulk delete requires ?confirm=true"})
+		return
+	}
+
+	force, ok := s.forceRequested(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := s.storage.DeleteSalesInRange(*from, *to, force)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	if deleted > 0 {
+		s.salesChanged("sales.deleted", gin.H{"from": from, "to": to, "count": deleted})
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// deleteAllSales wipes every sale, e.g. to reset a demo deployment. It is
+// refused with a 403 unless server.allow_dangerous_operations is set, and
+// like other bulk deletes it needs ?confirm=true.
+//
+// @Summary Permanently delete all sales
+// @Tags sales
+// @Produce json
+// @Param confirm query bool true "Must be true"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/all [delete]
+func (s *Server) deleteAllSales(c *gin.Context) {
+	if !s.cfg.Server.AllowDangerousOperations {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Deleting all sales is disabled; set server.allow_dangerous_operations to enable it"})
+		return
+	}
+	if c.Query("confirm") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Deleting all sales requires ?confirm=true"})
+		return
+	}
+
+	deleted, err := s.storage.DeleteAllSales()
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	s.logger.Warn("all sales deleted", "count", deleted, "request_id", c.GetString(requestIDKey))
+	s.salesChanged("sales.deleted", gin.H{"all": true, "count": deleted})
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// @Summary Restore a deleted sale
+// @Tags sales
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} models.Sale
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id}/restore [post]
+func (s *Server) restoreSale(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
 
-	analytics, err := s.storage.GetAnalytics(from, to)
+	sale, err := s.storage.RestoreSale(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, storage.ErrSaleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No deleted sale with that ID"})
+			return
+		}
+		respondInternalError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, analytics)
+	s.salesChanged("sale.restored", sale)
+	c.JSON(http.StatusOK, sale)
+}
+
+// getSaleHistory lists the earlier versions of a sale, oldest first.
+//
+// @Summary List the change history of a sale
+// @Tags sales
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id}/history [get]
+func (s *Server) getSaleHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	history, err := s.storage.GetSaleHistory(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrSaleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+type lockRequest struct {
+	Locked *bool `json:"locked" binding:"required"`
+}
+
+// @Summary Lock or unlock a sale
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param lock body lockRequest true "Lock state"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/{id}/lock [patch]
+func (s *Server) lockSale(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req lockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := s.storage.SetSaleLocked(id, *req.Locked); err != nil {
+		if errors.Is(err, storage.ErrSaleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sale not found"})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "locked": *req.Locked})
+}
+
+type recategorizeRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// recategorizeSales renames a category across all matching sales, e.g. to
+// fix a typo. Locked sales are left alone.
+//
+// @Summary Rename a category across sales
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param request body recategorizeRequest true "Old and new category"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth
+// @Router /items/recategorize [put]
+func (s *Server) recategorizeSales(c *gin.Context) {
+	var req recategorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if strings.TrimSpace(req.From) == "" || strings.TrimSpace(req.To) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Both from and to are required"})
+		return
+	}
+
+	updated, err := s.storage.RecategorizeSales(req.From, req.To)
+	if err != nil {
+		respondStorageError(c, err)
+		return
+	}
+	if updated > 0 {
+		s.salesChanged("sales.updated", gin.H{"from": req.From, "to": req.To, "count": updated})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
 }
 
-func (s *Server) exportCSV(c *gin.Context) {
-	// Implementation for CSV export
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented"})
+func respondVersionConflict(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{"error": "Sale was modified by another request; reload it and retry"})
+}
+
+// respondStorageError maps constraint violations to client errors (400 for
+// CHECK, 409 for UNIQUE) and anything else to a 500.
+func respondStorageError(c *gin.Context, err error) {
+	var constraintErr *storage.ConstraintError
+	switch {
+	case errors.As(err, &constraintErr) && errors.Is(err, storage.ErrCheckViolation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Value rejected by database constraint", "constraint": constraintErr.Constraint})
+	case errors.As(err, &constraintErr) && errors.Is(err, storage.ErrUniqueViolation):
+		c.JSON(http.StatusConflict, gin.H{"error": "Duplicate value", "constraint": constraintErr.Constraint})
+	default:
+		respondInternalError(c, err)
+	}
+}
+
+// forceRequested reports whether the request asks to override a sale lock.
+// Forcing requires the configured admin key in the X-Admin-Key header; if the
+// caller lacks it, a 403 is written and ok is false.
+func (s *Server) forceRequested(c *gin.Context) (force bool, ok bool) {
+	if c.Query("force") != "true" {
+		return false, true
+	}
+	if !s.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Force requires admin privileges"})
+		return false, false
+	}
+	return true, true
+}
+
+// limitAnalytics admits at most Analytics.MaxConcurrent analytics requests at
+// a time and rejects the overflow with a 503 rather than queueing it.
+func (s *Server) limitAnalytics(c *gin.Context) {
+	if s.analyticsSlots == nil {
+		c.Next()
+		return
+	}
+	select {
+	case s.analyticsSlots <- struct{}{}:
+		defer func() { <-s.analyticsSlots }()
+		c.Next()
+	default:
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Analytics is busy, try again shortly"})
+	}
+}
+
+// requireAdmin rejects requests that don't carry the configured admin key.
+// Admin routes are unreachable when no key is configured.
+func (s *Server) requireAdmin(c *gin.Context) {
+	if !s.isAdmin(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+	c.Next()
+}
+
+func (s *Server) isAdmin(c *gin.Context) bool {
+	key := s.cfg.Server.AdminKey
+	return key != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Key")), []byte(key)) == 1
+}
+
+// parseSaleFilter reads the list filters from the query string. On invalid
+// input it writes a 400 response and returns ok=false.
+func (s *Server) parseSaleFilter(c *gin.Context) (filter models.SaleFilter, ok bool) {
+	var err error
+	if filter.From, err = parseOptionalTime(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return filter, false
+	}
+	if filter.To, err = parseOptionalTime(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return filter, false
+	}
+	if near := c.Query("near"); near != "" {
+		if filter.Near, err = parseNear(near); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid near: " + err.Error()})
+			return filter, false
+		}
+	}
+	if saleType := c.Query("type"); saleType != "" {
+		if !saleTypes[saleType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type: must be income or expense"})
+			return filter, false
+		}
+		filter.Type = saleType
+	}
+	filter.Category = strings.TrimSpace(c.Query("category"))
+	filter.PaymentMethod = strings.TrimSpace(c.Query("method"))
+	if tags := c.QueryArray("tag"); len(tags) > 0 {
+		if filter.Tags, err = normalizeTags(tags); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag: " + err.Error()})
+			return filter, false
+		}
+	}
+	filter.Sort = c.DefaultQuery("sort", "date")
+	if !storage.SortableColumns[filter.Sort] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid sort %q: must be one of date, amount, category, id", filter.Sort)})
+		return filter, false
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order: must be asc or desc"})
+		return filter, false
+	}
+	if uncategorized := c.Query("uncategorized"); uncategorized != "" {
+		only, err := strconv.ParseBool(uncategorized)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid uncategorized flag"})
+			return filter, false
+		}
+		if only {
+			filter.Uncategorized = &s.cfg.Sales.DefaultCategory
+		}
+	}
+	return filter, true
+}
+
+// parseNear parses a "lat,lng,radiuskm" proximity filter.
+func parseNear(value string) (*models.GeoRadius, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return nil, errors.New("expected lat,lng,radiuskm")
+	}
+
+	var nums [3]float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, errors.New("expected lat,lng,radiuskm")
+		}
+		nums[i] = n
+	}
+
+	if err := validateCoordinates(nums[0], nums[1]); err != nil {
+		return nil, err
+	}
+	if nums[2] <= 0 {
+		return nil, errors.New("radius must be positive")
+	}
+
+	return &models.GeoRadius{Lat: nums[0], Lng: nums[1], RadiusKm: nums[2]}, nil
+}
+
+// parseOptionalTime parses an RFC3339 query value, returning nil when empty.
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
 }
@@ -0,0 +1,974 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"L3_6/internal/storage"
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockStore stubs the SaleStore methods a test sets; calling any other
+// method panics via the nil embedded interface.
+type mockStore struct {
+	SaleStore
+
+	createSale       func(sale *models.Sale) error
+	findDuplicate    func(sale *models.Sale, window time.Duration) (*models.Sale, error)
+	deleteSale       func(id int, force bool) error
+	getTopCategories func(from, to time.Time, saleType string, limit int) ([]models.CategoryTotal, error)
+	recategorize     func(from, to string) (int64, error)
+	deleteRange      func(from, to time.Time, force bool) (int64, error)
+	getAnalytics     func(from, to time.Time, percentiles ...float64) (*models.AnalyticsResponse, error)
+	lastModified     func(from, to time.Time) (time.Time, int64, error)
+	analyticsFilter  func(filter models.SaleFilter) (*models.AnalyticsResponse, error)
+	getSaleHistory   func(id int) ([]models.AuditEntry, error)
+	getSalesFiltered func(filter models.SaleFilter) ([]models.Sale, error)
+	getTagTotals     func(from, to time.Time, saleType string) ([]models.TagTotal, error)
+	sumByType        func(saleType string, from, to time.Time) (decimal.Decimal, error)
+	budgetStatus     func(month, tz string) ([]models.BudgetStatus, error)
+	createAttachment func(a *models.Attachment) error
+	getAttachment    func(saleID, id int) (*models.Attachment, error)
+	getPeriods       func(tz string) ([]models.Period, error)
+	countSales       func(filter models.SaleFilter) (int64, error)
+	schemaVersion    func() (uint, bool, error)
+	ping             func() error
+	bulkUpdate       func(filter models.SaleFilter, fields map[string]any, force bool) (int64, error)
+	getSale          func(id int) (*models.Sale, error)
+	createBatch      func(sales []models.Sale) error
+	createEach       func(sales []models.Sale) []error
+	getTopSales      func(from, to time.Time, saleType string, limit int) ([]models.Sale, error)
+	getRecentSales   func(limit int) ([]models.Sale, error)
+	getMethodTotals  func(from, to time.Time, saleType string) ([]models.PaymentMethodTotal, error)
+	getDistribution  func(from, to time.Time, dimension, saleType, tz string) ([]models.DistributionBucket, error)
+	getDaily         func(from, to time.Time, tz string) ([]models.DailyTotal, error)
+	categoryUsage    func() ([]models.CategoryUsage, error)
+	createCategory   func(name string) (*models.Category, int64, error)
+	renameCategory   func(id int, name string) (*models.Category, int64, error)
+	getNetWorth      func(from, to time.Time, interval, tz string) ([]models.NetWorthPoint, error)
+	deleteAll        func() (int64, error)
+	updateSale       func(sale *models.Sale, force bool) error
+	createDelivery   func(d *models.WebhookDelivery) error
+	updateDelivery   func(d *models.WebhookDelivery) error
+}
+
+func (m *mockStore) CreateWebhookDelivery(d *models.WebhookDelivery) error {
+	return m.createDelivery(d)
+}
+
+func (m *mockStore) UpdateWebhookDelivery(d *models.WebhookDelivery) error {
+	return m.updateDelivery(d)
+}
+
+func (m *mockStore) CreateSale(sale *models.Sale) error { return m.createSale(sale) }
+
+func (m *mockStore) FindPotentialDuplicate(sale *models.Sale, window time.Duration) (*models.Sale, error) {
+	return m.findDuplicate(sale, window)
+}
+
+func (m *mockStore) UpdateSale(sale *models.Sale, force bool) error {
+	return m.updateSale(sale, force)
+}
+
+func (m *mockStore) DeleteSale(id int, force bool) error { return m.deleteSale(id, force) }
+
+func (m *mockStore) GetTopCategories(from, to time.Time, saleType string, limit int) ([]models.CategoryTotal, error) {
+	return m.getTopCategories(from, to, saleType, limit)
+}
+
+func (m *mockStore) RecategorizeSales(from, to string) (int64, error) {
+	return m.recategorize(from, to)
+}
+
+func (m *mockStore) DeleteSalesInRange(from, to time.Time, force bool) (int64, error) {
+	return m.deleteRange(from, to, force)
+}
+
+func (m *mockStore) GetAnalytics(from, to time.Time, percentiles ...float64) (*models.AnalyticsResponse, error) {
+	return m.getAnalytics(from, to, percentiles...)
+}
+
+func (m *mockStore) GetAnalyticsFiltered(filter models.SaleFilter, _ ...float64) (*models.AnalyticsResponse, error) {
+	return m.analyticsFilter(filter)
+}
+
+func (m *mockStore) LastModified(from, to time.Time) (time.Time, int64, error) {
+	return m.lastModified(from, to)
+}
+
+func (m *mockStore) GetSaleHistory(id int) ([]models.AuditEntry, error) {
+	return m.getSaleHistory(id)
+}
+
+func (m *mockStore) GetSalesFiltered(filter models.SaleFilter) ([]models.Sale, error) {
+	return m.getSalesFiltered(filter)
+}
+
+func (m *mockStore) GetTagTotals(from, to time.Time, saleType string) ([]models.TagTotal, error) {
+	return m.getTagTotals(from, to, saleType)
+}
+
+func (m *mockStore) SumByType(saleType string, from, to time.Time) (decimal.Decimal, error) {
+	return m.sumByType(saleType, from, to)
+}
+
+func (m *mockStore) GetBudgetStatus(month, tz string) ([]models.BudgetStatus, error) {
+	return m.budgetStatus(month, tz)
+}
+
+func (m *mockStore) CreateAttachment(a *models.Attachment) error { return m.createAttachment(a) }
+
+func (m *mockStore) GetAttachment(saleID, id int) (*models.Attachment, error) {
+	return m.getAttachment(saleID, id)
+}
+
+func (m *mockStore) GetPeriods(tz string) ([]models.Period, error) { return m.getPeriods(tz) }
+
+func (m *mockStore) CountSales(filter models.SaleFilter) (int64, error) { return m.countSales(filter) }
+
+func (m *mockStore) SchemaVersion(ctx context.Context) (uint, bool, error) { return m.schemaVersion() }
+
+func (m *mockStore) Ping(ctx context.Context) error { return m.ping() }
+
+func (m *mockStore) GetSale(id int) (*models.Sale, error) { return m.getSale(id) }
+
+func (m *mockStore) GetPaymentMethodTotals(from, to time.Time, saleType string) ([]models.PaymentMethodTotal, error) {
+	return m.getMethodTotals(from, to, saleType)
+}
+
+func (m *mockStore) GetRecentSales(limit int) ([]models.Sale, error) {
+	return m.getRecentSales(limit)
+}
+
+func (m *mockStore) GetTopSales(from, to time.Time, saleType string, limit int) ([]models.Sale, error) {
+	return m.getTopSales(from, to, saleType, limit)
+}
+
+func (m *mockStore) CreateSalesEach(sales []models.Sale) []error {
+	return m.createEach(sales)
+}
+
+func (m *mockStore) CreateSalesBatch(sales []models.Sale) error { return m.createBatch(sales) }
+
+func (m *mockStore) GetDailyExpenses(from, to time.Time, tz string) ([]models.DailyTotal, error) {
+	return m.getDaily(from, to, tz)
+}
+
+func (m *mockStore) GetCategories() ([]models.CategoryUsage, error) { return m.categoryUsage() }
+
+func (m *mockStore) CreateCategory(name string) (*models.Category, int64, error) {
+	return m.createCategory(name)
+}
+
+func (m *mockStore) RenameCategory(id int, name string) (*models.Category, int64, error) {
+	return m.renameCategory(id, name)
+}
+
+func (m *mockStore) GetNetWorth(from, to time.Time, interval, tz string) ([]models.NetWorthPoint, error) {
+	return m.getNetWorth(from, to, interval, tz)
+}
+
+func (m *mockStore) GetDistribution(from, to time.Time, dimension, saleType, tz string) ([]models.DistributionBucket, error) {
+	return m.getDistribution(from, to, dimension, saleType, tz)
+}
+
+func (m *mockStore) DeleteAllSales() (int64, error) { return m.deleteAll() }
+
+// StreamSales replays getSalesFiltered: the sales it returns are streamed,
+// then its error, so a stub returning both fails part way through. Like a
+// real query it stops once ctx is done.
+func (m *mockStore) StreamSales(ctx context.Context, filter models.SaleFilter, fn func(models.Sale) error) error {
+	sales, err := m.getSalesFiltered(filter)
+	for _, sale := range sales {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(sale); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (m *mockStore) BulkUpdateSales(filter models.SaleFilter, fields map[string]any, force bool) (int64, error) {
+	return m.bulkUpdate(filter, fields, force)
+}
+
+func serve(srv *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	return w
+}
+
+// sentWebhook is a webhook body received by recordWebhooks.
+type sentWebhook struct {
+	Event string         `json:"event"`
+	Data  map[string]any `json:"data"`
+}
+
+// recordWebhooks points cfg's webhooks at a test endpoint, stubbing the
+// delivery records on store, and returns the webhooks it receives. cfg must
+// be passed to NewServer afterwards.
+func recordWebhooks(t *testing.T, store *mockStore, cfg *models.Config) <-chan sentWebhook {
+	t.Helper()
+	received := make(chan sentWebhook, 16)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hook sentWebhook
+		if err := json.NewDecoder(r.Body).Decode(&hook); err == nil {
+			received <- hook
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	var mu sync.Mutex
+	nextID := 0
+	store.createDelivery = func(d *models.WebhookDelivery) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nextID++
+		d.ID = nextID
+		return nil
+	}
+	store.updateDelivery = func(*models.WebhookDelivery) error { return nil }
+	cfg.Webhooks.URLs = []string{endpoint.URL}
+	cfg.Webhooks.MaxAttempts = 1
+	return received
+}
+
+// awaitWebhook returns the next webhook recordWebhooks received, failing the
+// test if none arrives.
+func awaitWebhook(t *testing.T, received <-chan sentWebhook) sentWebhook {
+	t.Helper()
+	select {
+	case hook := <-received:
+		return hook
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook was sent")
+		return sentWebhook{}
+	}
+}
+
+func TestCreateSale_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"type":"expense","amount":"12.50","date":"2024-01-15T10:30:00Z","category":"Food"}`
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		srv := NewServer(&mockStore{}, &models.Config{})
+		w := serve(srv, http.MethodPost, "/api/items", `{"type":`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		store := &mockStore{createSale: func(*models.Sale) error { return errors.New("connection reset") }}
+		srv := NewServer(store, &models.Config{})
+		w := serve(srv, http.MethodPost, "/api/items", body)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("constraint violation", func(t *testing.T) {
+		store := &mockStore{createSale: func(*models.Sale) error {
+			return fmt.Errorf("storage.CreateSale: %w", &storage.ConstraintError{Kind: storage.ErrCheckViolation, Constraint: "sales_amount_check"})
+		}}
+		srv := NewServer(store, &models.Config{})
+		w := serve(srv, http.MethodPost, "/api/items", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("created", func(t *testing.T) {
+		var stored models.Sale
+		store := &mockStore{createSale: func(sale *models.Sale) error {
+			sale.ID = 42
+			stored = *sale
+			return nil
+		}}
+		srv := NewServer(store, &models.Config{})
+		w := serve(srv, http.MethodPost, "/api/items", body)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var got models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, 42, got.ID)
+		assert.Equal(t, "Food", got.Category)
+		assert.True(t, got.Amount.Equal(decimal.RequireFromString("12.50")))
+		assert.Equal(t, stored.Date, got.Date)
+		assert.Equal(t, "/api/items/42", w.Header().Get("Location"))
+	})
+
+	t.Run("location includes base path", func(t *testing.T) {
+		store := &mockStore{createSale: func(sale *models.Sale) error {
+			sale.ID = 7
+			return nil
+		}}
+		cfg := &models.Config{}
+		cfg.Server.BasePath = "/finance/"
+		srv := NewServer(store, cfg)
+		w := serve(srv, http.MethodPost, "/finance/api/items", body)
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/finance/api/items/7", w.Header().Get("Location"))
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		created := 0
+		store := &mockStore{
+			createSale: func(sale *models.Sale) error {
+				created++
+				return nil
+			},
+			findDuplicate: func(*models.Sale, time.Duration) (*models.Sale, error) {
+				return &models.Sale{ID: 3}, nil
+			},
+		}
+		cfg := &models.Config{}
+		cfg.Sales.DuplicateWindow = 10 * time.Minute
+		srv := NewServer(store, cfg)
+
+		assert.Equal(t, http.StatusConflict, serve(srv, http.MethodPost, "/api/items", body).Code)
+		assert.Equal(t, http.StatusConflict, serve(srv, http.MethodPost, "/api/items?force=true", body).Code, "force only overrides locks")
+		assert.Zero(t, created)
+
+		assert.Equal(t, http.StatusCreated, serve(srv, http.MethodPost, "/api/items?allow_duplicate=true", body).Code)
+		assert.Equal(t, 1, created)
+	})
+}
+
+func TestDeleteAllSales_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	store := &mockStore{deleteAll: func() (int64, error) {
+		calls++
+		return 5, nil
+	}}
+
+	srv := NewServer(store, &models.Config{})
+	assert.Equal(t, http.StatusForbidden, serve(srv, http.MethodDelete, "/api/items/all?confirm=true", "").Code, "disabled by default")
+
+	cfg := &models.Config{}
+	cfg.Server.AllowDangerousOperations = true
+	srv = NewServer(store, cfg)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodDelete, "/api/items/all", "").Code)
+	assert.Zero(t, calls)
+
+	w := serve(srv, http.MethodDelete, "/api/items/all?confirm=true", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"deleted":5}`, w.Body.String())
+	assert.Equal(t, 1, calls)
+}
+
+func TestCreateSalesBatch_Links(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &mockStore{createBatch: func(sales []models.Sale) error {
+		for i := range sales {
+			sales[i].ID = 10 + i
+		}
+		return nil
+	}}
+	srv := NewServer(store, &models.Config{})
+
+	w := serve(srv, http.MethodPost, "/api/items/batch", `[
+		{"type":"income","amount":10,"date":"2024-01-15T10:30:00Z","category":"Salary"},
+		{"type":"expense","amount":5,"date":"2024-01-16T10:30:00Z","category":"Food"}
+	]`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `</api/items/10>; rel="item", </api/items/11>; rel="item"`, w.Header().Get("Link"))
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+func TestCreateSalesBatch_BestEffort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var stored []models.Sale
+	store := &mockStore{createEach: func(sales []models.Sale) []error {
+		stored = sales
+		errs := make([]error, len(sales))
+		for i := range sales {
+			if sales[i].Category == "Duplicate" {
+				errs[i] = fmt.Errorf("storage.CreateSale: %w", &storage.ConstraintError{Kind: storage.ErrUniqueViolation, Constraint: "sales_pkey"})
+				continue
+			}
+			sales[i].ID = 10 + i
+		}
+		return errs
+	}}
+	srv := NewServer(store, &models.Config{})
+
+	w := serve(srv, http.MethodPost, "/api/items/batch?mode=best-effort", `[
+		{"type":"income","amount":10,"date":"2024-01-15T10:30:00Z","category":"Salary"},
+		{"type":"refund","amount":5,"date":"2024-01-16T10:30:00Z","category":"Food"},
+		{"type":"expense","amount":5,"date":"2024-01-16T10:30:00Z","category":"Duplicate"},
+		{"type":"expense","amount":7,"date":"2024-01-17T10:30:00Z","category":"Food"}
+	]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, stored, 3, "the invalid sale never reaches the store")
+
+	var resp batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Created)
+	assert.Equal(t, 2, resp.Failed)
+	require.Len(t, resp.Results, 4)
+	assert.Equal(t, batchResult{Index: 0, ID: 10}, resp.Results[0])
+	assert.Equal(t, 1, resp.Results[1].Index)
+	assert.Contains(t, resp.Results[1].Error, "refund")
+	assert.Equal(t, batchResult{Index: 2, Error: "Duplicate value", Constraint: "sales_pkey"}, resp.Results[2])
+	assert.Equal(t, batchResult{Index: 3, ID: 12}, resp.Results[3])
+	assert.Equal(t, `</api/items/10>; rel="item", </api/items/12>; rel="item"`, w.Header().Get("Link"))
+
+	t.Run("store failure is not echoed", func(t *testing.T) {
+		srv := NewServer(&mockStore{createEach: func(sales []models.Sale) []error {
+			return []error{errors.New("storage.CreateSale: dial tcp 10.0.0.3:5432: connection refused")}
+		}}, &models.Config{})
+		w := serve(srv, http.MethodPost, "/api/items/batch?mode=best-effort", `[{"type":"expense","amount":7,"date":"2024-01-17T10:30:00Z","category":"Food"}]`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"Internal server error"`)
+		assert.NotContains(t, w.Body.String(), "10.0.0.3")
+	})
+
+	t.Run("every sale invalid", func(t *testing.T) {
+		stored = nil
+		w := serve(srv, http.MethodPost, "/api/items/batch?mode=best-effort", `[{"type":"refund","amount":5,"date":"2024-01-16T10:30:00Z","category":"Food"}]`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, stored)
+		assert.Contains(t, w.Body.String(), `"failed":1`)
+		assert.Empty(t, w.Header().Get("Link"))
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		w := serve(srv, http.MethodPost, "/api/items/batch?mode=partial", `[]`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetTopSales_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotType string
+	var gotLimit int
+	var gotFrom, gotTo time.Time
+	srv := NewServer(&mockStore{getTopSales: func(from, to time.Time, saleType string, limit int) ([]models.Sale, error) {
+		gotFrom, gotTo, gotType, gotLimit = from, to, saleType, limit
+		sale := validSale()
+		sale.ID = 3
+		return []models.Sale{sale}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items/top?from=2024-01-01&to=2024-01-31", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var sales []models.Sale
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sales))
+	require.Len(t, sales, 1)
+	assert.Equal(t, 3, sales[0].ID)
+	assert.Equal(t, "expense", gotType)
+	assert.Equal(t, defaultTopSales, gotLimit)
+	assert.True(t, gotFrom.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, gotTo.Equal(time.Date(2024, 1, 31, 23, 59, 59, 999999999, time.UTC)))
+
+	w = serve(srv, http.MethodGet, "/api/items/top?from=2024-01-01&to=2024-01-31&type=income&limit=500", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "income", gotType)
+	assert.Equal(t, maxTopSales, gotLimit)
+
+	for _, query := range []string{"&limit=0", "&limit=ten", "&type=transfer", "&tz=Mars/Olympus"} {
+		w := serve(srv, http.MethodGet, "/api/items/top?from=2024-01-01&to=2024-01-31"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetRecentSales_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotLimit int
+	srv := NewServer(&mockStore{getRecentSales: func(limit int) ([]models.Sale, error) {
+		gotLimit = limit
+		return []models.Sale{}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items/recent", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, defaultRecentSales, gotLimit)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	w = serve(srv, http.MethodGet, "/api/items/recent?limit=3", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, gotLimit)
+
+	w = serve(srv, http.MethodGet, "/api/items/recent?limit=1000", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, maxRecentSales, gotLimit)
+
+	for _, limit := range []string{"0", "-5", "ten"} {
+		w := serve(srv, http.MethodGet, "/api/items/recent?limit="+limit, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+	}
+}
+
+func TestGetSale_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(&mockStore{getSale: func(id int) (*models.Sale, error) {
+		if id != 42 {
+			return nil, fmt.Errorf("storage.GetSale: %w", storage.ErrSaleNotFound)
+		}
+		sale := validSale()
+		sale.ID = id
+		return &sale, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items/42", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var got models.Sale
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, 42, got.ID)
+
+	assert.Equal(t, http.StatusNotFound, serve(srv, http.MethodGet, "/api/items/7", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/items/abc", "").Code)
+}
+
+func TestNewServer_GinMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(func() { gin.SetMode(gin.TestMode) })
+
+	NewServer(&mockStore{}, &models.Config{})
+	assert.Equal(t, gin.TestMode, gin.Mode(), "an empty mode leaves Gin's alone")
+
+	cfg := &models.Config{}
+	cfg.Server.GinMode = gin.ReleaseMode
+	NewServer(&mockStore{}, cfg)
+	assert.Equal(t, gin.ReleaseMode, gin.Mode())
+}
+
+func TestUpdateSale_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fields := `"type":"expense","amount":"12.50","date":"2024-01-15T10:30:00Z","category":"Food"`
+
+	var updated []int
+	srv := NewServer(&mockStore{updateSale: func(sale *models.Sale, force bool) error {
+		updated = append(updated, sale.ID)
+		return nil
+	}}, &models.Config{})
+
+	for name, body := range map[string]string{
+		"no body id":       `{` + fields + `}`,
+		"matching body id": `{"id":7,` + fields + `}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			updated = nil
+			w := serve(srv, http.MethodPut, "/api/items/7", body)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, []int{7}, updated)
+			assert.Contains(t, w.Body.String(), `"id":7`)
+		})
+	}
+
+	t.Run("mismatching body id", func(t *testing.T) {
+		updated = nil
+		w := serve(srv, http.MethodPut, "/api/items/7", `{"id":8,`+fields+`}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "does not match")
+		assert.Empty(t, updated, "no sale is updated")
+	})
+}
+
+func TestDeleteSale_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("bad ID", func(t *testing.T) {
+		srv := NewServer(&mockStore{}, &models.Config{})
+		w := serve(srv, http.MethodDelete, "/api/items/abc", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		var deleted int
+		store := &mockStore{deleteSale: func(id int, force bool) error {
+			deleted = id
+			assert.False(t, force)
+			return nil
+		}}
+		srv := NewServer(store, &models.Config{})
+		w := serve(srv, http.MethodDelete, "/api/items/7", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, 7, deleted)
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		store := &mockStore{deleteSale: func(int, bool) error {
+			return fmt.Errorf("storage.DeleteSale: %w", storage.ErrSaleLocked)
+		}}
+		srv := NewServer(store, &models.Config{})
+		w := serve(srv, http.MethodDelete, "/api/items/7", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		store := &mockStore{deleteSale: func(int, bool) error { return errors.New("connection reset") }}
+		srv := NewServer(store, &models.Config{})
+		w := serve(srv, http.MethodDelete, "/api/items/7", "")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestListCategoryUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(&mockStore{categoryUsage: func() ([]models.CategoryUsage, error) {
+		return []models.CategoryUsage{{Name: "Food", Count: 3}, {Name: "Rent", Count: 1}}, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/categories/usage", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"name":"Food","count":3},{"name":"Rent","count":1}]`, w.Body.String())
+}
+
+func TestCategoryWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &mockStore{
+		createCategory: func(name string) (*models.Category, int64, error) {
+			return &models.Category{ID: 4, Name: name, SaleCount: 2}, 2, nil
+		},
+		renameCategory: func(id int, name string) (*models.Category, int64, error) {
+			return &models.Category{ID: id, Name: name, SaleCount: 3}, 3, nil
+		},
+	}
+	cfg := &models.Config{}
+	webhooks := recordWebhooks(t, store, cfg)
+	srv := NewServer(store, cfg)
+
+	require.Equal(t, http.StatusCreated, serve(srv, http.MethodPost, "/api/categories", `{"name":"Food"}`).Code)
+	hook := awaitWebhook(t, webhooks)
+	assert.Equal(t, "sales.updated", hook.Event)
+	assert.Equal(t, map[string]any{"category_id": 4.0, "category": "Food", "count": 2.0}, hook.Data, "linked sales are reported")
+
+	require.Equal(t, http.StatusOK, serve(srv, http.MethodPut, "/api/categories/4", `{"name":"Groceries"}`).Code)
+	hook = awaitWebhook(t, webhooks)
+	assert.Equal(t, "sales.updated", hook.Event)
+	assert.Equal(t, map[string]any{"category_id": 4.0, "category": "Groceries", "count": 3.0}, hook.Data)
+}
+
+func TestGetSaleHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &mockStore{getSaleHistory: func(id int) ([]models.AuditEntry, error) {
+		if id != 7 {
+			return nil, fmt.Errorf("storage.GetSaleHistory: %w", storage.ErrSaleNotFound)
+		}
+		return []models.AuditEntry{
+			{ID: 1, SaleID: 7, Action: "update", Category: "Food", Version: 1},
+			{ID: 2, SaleID: 7, Action: "delete", Category: "Groceries", Version: 2},
+		}, nil
+	}}
+	srv := NewServer(store, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items/7/history", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []models.AuditEntry
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, "update", history[0].Action)
+	assert.Equal(t, "Groceries", history[1].Category)
+
+	assert.Equal(t, http.StatusNotFound, serve(srv, http.MethodGet, "/api/items/8/history", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/items/abc/history", "").Code)
+}
+
+func TestHTTPServer_Timeouts(t *testing.T) {
+	cfg := &models.Config{}
+	cfg.Server.ReadTimeout = 15 * time.Second
+	cfg.Server.WriteTimeout = 20 * time.Second
+	cfg.Server.IdleTimeout = time.Minute
+	srv := NewServer(nil, cfg)
+
+	hs := srv.httpServer(":8080")
+	assert.Equal(t, ":8080", hs.Addr)
+	assert.Equal(t, 15*time.Second, hs.ReadTimeout)
+	assert.Equal(t, 15*time.Second, hs.ReadHeaderTimeout)
+	assert.Equal(t, 20*time.Second, hs.WriteTimeout)
+	assert.Equal(t, time.Minute, hs.IdleTimeout)
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/openapi.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var spec struct {
+		BasePath string                     `json:"basePath"`
+		Paths    map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "/api", spec.BasePath)
+	for _, path := range []string{"/items", "/items/{id}", "/categories", "/budgets", "/recurring", "/analytics", "/analytics/timeseries"} {
+		assert.Contains(t, spec.Paths, path)
+	}
+
+	w = serve(srv, http.MethodGet, "/swagger/index.html", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Server.BasePath = "/finance/"
+	srv := NewServer(nil, cfg)
+
+	assert.Equal(t, http.StatusOK, serve(srv, http.MethodGet, "/finance/health", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(srv, http.MethodGet, "/health", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/finance/api/items/search", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(srv, http.MethodGet, "/api/items/search", "").Code)
+
+	w := serve(srv, http.MethodGet, "/finance/openapi.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var spec struct {
+		BasePath string `json:"basePath"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "/finance/api", spec.BasePath)
+}
+
+func TestApplySalePatch(t *testing.T) {
+	sale := validSale()
+	category := "Groceries"
+	amount := decimal.RequireFromString("20.00")
+
+	columns := applySalePatch(&sale, models.SalePatch{Category: &category, Amount: &amount})
+
+	assert.Equal(t, []string{"amount", "category"}, columns)
+	assert.Equal(t, "Groceries", sale.Category)
+	assert.True(t, amount.Equal(sale.Amount))
+	assert.Equal(t, "expense", sale.Type, "untouched fields keep their values")
+
+	values := saleColumnValues(&sale, columns)
+	assert.Equal(t, map[string]any{"amount": amount, "category": "Groceries"}, values)
+
+	assert.Empty(t, applySalePatch(&sale, models.SalePatch{}))
+
+	tags := []string{"travel"}
+	assert.Equal(t, []string{"tags"}, applySalePatch(&sale, models.SalePatch{Tags: &tags}))
+	assert.Equal(t, tags, sale.Tags)
+
+	note := "split with Anna"
+	assert.Equal(t, []string{"note"}, applySalePatch(&sale, models.SalePatch{Note: &note}))
+	assert.Equal(t, map[string]any{"note": note}, saleColumnValues(&sale, []string{"note"}))
+
+	method := "cash"
+	assert.Equal(t, []string{"payment_method"}, applySalePatch(&sale, models.SalePatch{PaymentMethod: &method}))
+	assert.Equal(t, map[string]any{"payment_method": method}, saleColumnValues(&sale, []string{"payment_method"}))
+}
+
+func TestCountSales(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got models.SaleFilter
+	srv := NewServer(&mockStore{countSales: func(filter models.SaleFilter) (int64, error) {
+		got = filter
+		return 42, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items/count?type=expense&category=%20Food%20&from=2024-01-01T00:00:00Z", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count":42}`, w.Body.String())
+	assert.Equal(t, "expense", got.Type)
+	assert.Equal(t, "Food", got.Category)
+	require.NotNil(t, got.From)
+
+	for _, query := range []string{"?type=transfer", "?from=yesterday"} {
+		assert.Equal(t, http.StatusBadRequest, serve(srv, http.MethodGet, "/api/items/count"+query, "").Code, query)
+	}
+}
+
+func TestGetSales_TagFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got models.SaleFilter
+	srv := NewServer(&mockStore{getSalesFiltered: func(filter models.SaleFilter) ([]models.Sale, error) {
+		got = filter
+		return nil, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items?tag=Travel&tag=work&tag=travel", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"travel", "work"}, got.Tags)
+
+	w = serve(srv, http.MethodGet, "/api/items?tag="+strings.Repeat("x", maxTagLength+1), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetSales_MethodFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got models.SaleFilter
+	srv := NewServer(&mockStore{getSalesFiltered: func(filter models.SaleFilter) ([]models.Sale, error) {
+		got = filter
+		return nil, nil
+	}}, &models.Config{})
+
+	w := serve(srv, http.MethodGet, "/api/items?method=%20Card%20", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Card", got.PaymentMethod, "case is ignored by the query")
+
+	w = serve(srv, http.MethodGet, "/api/items", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, got.PaymentMethod)
+}
+
+func TestSearchSales_EmptyQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	for _, path := range []string{"/api/items/search", "/api/items/search?q=%20%20"} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+func TestGetSales_InvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	for _, query := range []string{"sort=locked", "sort=amount&order=sideways", "balance=maybe"} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/items?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestDeleteSalesInRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotFrom, gotTo time.Time
+	store := &mockStore{deleteRange: func(from, to time.Time, force bool) (int64, error) {
+		gotFrom, gotTo = from, to
+		return 4, nil
+	}}
+	srv := NewServer(store, &models.Config{})
+	const rng = "from=2024-01-01T00:00:00Z&to=2024-01-31T23:59:59Z"
+
+	w := serve(srv, http.MethodDelete, "/api/items?"+rng+"&confirm=true", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"deleted":4}`, w.Body.String())
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), gotFrom)
+	assert.Equal(t, time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC), gotTo)
+
+	for _, query := range []string{
+		"confirm=true",
+		"from=2024-01-01T00:00:00Z&confirm=true",
+		rng,
+		rng + "&confirm=yes",
+		"from=yesterday&to=2024-01-31T23:59:59Z&confirm=true",
+	} {
+		w := serve(srv, http.MethodDelete, "/api/items?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestRecategorizeSales(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var gotFrom, gotTo string
+	updated := int64(3)
+	store := &mockStore{recategorize: func(from, to string) (int64, error) {
+		gotFrom, gotTo = from, to
+		return updated, nil
+	}}
+	cfg := &models.Config{}
+	webhooks := recordWebhooks(t, store, cfg)
+	srv := NewServer(store, cfg)
+
+	w := serve(srv, http.MethodPut, "/api/items/recategorize", `{"from":"food","to":"Food"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"updated":3}`, w.Body.String())
+	assert.Equal(t, "food", gotFrom)
+	assert.Equal(t, "Food", gotTo)
+
+	hook := awaitWebhook(t, webhooks)
+	assert.Equal(t, "sales.updated", hook.Event)
+	assert.Equal(t, map[string]any{"from": "food", "to": "Food", "count": 3.0}, hook.Data)
+
+	// Nothing to notify when no sale had the category.
+	updated = 0
+	require.Equal(t, http.StatusOK, serve(srv, http.MethodPut, "/api/items/recategorize", `{"from":"none","to":"Food"}`).Code)
+	select {
+	case hook := <-webhooks:
+		t.Fatalf("unexpected webhook %q", hook.Event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for _, body := range []string{`{"from":"","to":"Food"}`, `{"from":"food","to":"  "}`, `{}`, `not json`} {
+		w := serve(srv, http.MethodPut, "/api/items/recategorize", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestBudgetValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	for name, tc := range map[string]struct{ method, path, body string }{
+		"missing category": {http.MethodPost, "/api/budgets", `{"month":"2024-01","limit":"100"}`},
+		"blank category":   {http.MethodPost, "/api/budgets", `{"category":" ","month":"2024-01","limit":"100"}`},
+		"bad month":        {http.MethodPost, "/api/budgets", `{"category":"Food","month":"2024-1","limit":"100"}`},
+		"zero limit":       {http.MethodPost, "/api/budgets", `{"category":"Food","month":"2024-01","limit":"0"}`},
+		"limit overflow":   {http.MethodPost, "/api/budgets", `{"category":"Food","month":"2024-01","limit":"100000000"}`},
+		"bad id":           {http.MethodPut, "/api/budgets/abc", `{"category":"Food","month":"2024-01","limit":"100"}`},
+		"bad status month": {http.MethodGet, "/api/budgets/status?month=January", ``},
+		"bad list month":   {http.MethodGet, "/api/budgets?month=2024-13", ``},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
+
+func TestRecurringRuleValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	valid := `"type":"expense","amount":"900","category":"Rent","next_date":"2024-02-01T00:00:00Z"`
+	for name, tc := range map[string]struct{ method, path, body string }{
+		"unknown interval": {http.MethodPost, "/api/recurring", `{` + valid + `,"interval":"hourly"}`},
+		"missing interval": {http.MethodPost, "/api/recurring", `{` + valid + `}`},
+		"bad type":         {http.MethodPost, "/api/recurring", `{"type":"gift","amount":"1","category":"x","next_date":"2024-02-01T00:00:00Z","interval":"daily"}`},
+		"missing date":     {http.MethodPost, "/api/recurring", `{"type":"expense","amount":"1","category":"x","interval":"daily"}`},
+		"bad id":           {http.MethodPut, "/api/recurring/abc", `{` + valid + `,"interval":"monthly"}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
+
+func TestCategoryValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	for name, tc := range map[string]struct{ method, path, body string }{
+		"missing name": {http.MethodPost, "/api/categories", `{}`},
+		"blank name":   {http.MethodPost, "/api/categories", `{"name":"   "}`},
+		"long name":    {http.MethodPost, "/api/categories", `{"name":"` + strings.Repeat("x", 256) + `"}`},
+		"bad id":       {http.MethodPut, "/api/categories/abc", `{"name":"Food"}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// connCounter follows the connections of an http.Server through its
+// ConnState hook, so a shutdown can report how much it has to drain. The zero
+// value is ready to use.
+type connCounter struct {
+	mu     sync.Mutex
+	states map[net.Conn]http.ConnState
+}
+
+func (c *connCounter) track(conn net.Conn, state http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state == http.StateHijacked || state == http.StateClosed {
+		delete(c.states, conn)
+		return
+	}
+	if c.states == nil {
+		c.states = make(map[net.Conn]http.ConnState)
+	}
+	c.states[conn] = state
+}
+
+// counts returns the number of connections serving a request and the number
+// of connections that are open but idle or not yet read from.
+func (c *connCounter) counts() (active, idle int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, state := range c.states {
+		if state == http.StateActive {
+			active++
+		} else {
+			idle++
+		}
+	}
+	return active, idle
+}
+
+// shutdown stops srv from accepting connections and waits up to the
+// configured timeout for in-flight requests to finish, logging what it is
+// draining and how it went. Connections still busy at the deadline are closed
+// and context.DeadlineExceeded is returned.
+func (s *Server) shutdown(srv *http.Server) error {
+	timeout := s.cfg.Server.ShutdownTimeout
+	active, idle := s.conns.counts()
+	s.logger.Info("shutdown started", "active_conns", active, "idle_conns", idle, "timeout", timeout)
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		active, _ := s.conns.counts()
+		s.logger.Warn("shutdown timed out, closing remaining connections", "active_conns", active, "timeout", timeout)
+		srv.Close()
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("shutdown complete, all requests drained")
+	return nil
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer safe to log to from the server's goroutines
+// while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// freePort returns a local port that was free a moment ago.
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestRun_Shutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Without keep-alives no connection outlives its request: a spare one the
+	// transport dialed for /slow, but never used, would hold up the shutdown.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	// start runs a server with a /slow route that signals entered and then
+	// blocks until release is closed, returning a channel with Run's result.
+	start := func(t *testing.T, timeout time.Duration, entered, release chan struct{}) (context.CancelFunc, chan error, *syncBuffer, string) {
+		cfg := &models.Config{}
+		cfg.Server.ShutdownTimeout = timeout
+		srv := NewServer(nil, cfg)
+		var logs syncBuffer
+		srv.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+		srv.router.GET("/slow", func(c *gin.Context) {
+			close(entered)
+			<-release
+			c.Status(http.StatusOK)
+		})
+
+		port := freePort(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- srv.Run(ctx, port) }()
+		require.Eventually(t, func() bool {
+			resp, err := client.Get("http://127.0.0.1:" + port + "/health")
+			if err == nil {
+				resp.Body.Close()
+			}
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+		return cancel, done, &logs, port
+	}
+
+	t.Run("drains in-flight requests", func(t *testing.T) {
+		entered, release := make(chan struct{}), make(chan struct{})
+		cancel, done, logs, port := start(t, 5*time.Second, entered, release)
+
+		status := make(chan int, 1)
+		go func() {
+			resp, err := client.Get("http://127.0.0.1:" + port + "/slow")
+			if err != nil {
+				status <- 0
+				return
+			}
+			resp.Body.Close()
+			status <- resp.StatusCode
+		}()
+		select {
+		case <-entered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("/slow was never reached")
+		}
+
+		cancel()
+		require.Eventually(t, func() bool { return strings.Contains(logs.String(), "shutdown started") }, 5*time.Second, 10*time.Millisecond)
+		close(release)
+
+		require.NoError(t, <-done)
+		assert.Equal(t, http.StatusOK, <-status)
+		assert.Contains(t, logs.String(), `"active_conns":1`)
+		assert.Contains(t, logs.String(), "shutdown complete")
+	})
+
+	t.Run("gives up at the timeout", func(t *testing.T) {
+		entered, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		cancel, done, logs, port := start(t, 50*time.Millisecond, entered, release)
+
+		go func() {
+			if resp, err := client.Get("http://127.0.0.1:" + port + "/slow"); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		select {
+		case <-entered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("/slow was never reached")
+		}
+
+		cancel()
+		assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+		assert.Contains(t, logs.String(), "shutdown timed out")
+	})
+}
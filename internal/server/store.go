@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"L3_6/internal/storage"
+	"L3_6/internal/webhook"
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+var _ SaleStore = (*storage.Storage)(nil)
+
+// SaleStore is the persistence the server depends on. *storage.Storage
+// implements it; tests can substitute an in-memory fake. Errors are expected
+// to wrap the storage package's sentinels (storage.ErrSaleNotFound and so
+// on), which the handlers map to status codes.
+type SaleStore interface {
+	webhook.Store
+
+	Ping(ctx context.Context) error
+	PoolStat() *pgxpool.Stat
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+
+	CreateSale(sale *models.Sale) error
+	CreateSaleIdempotent(sale *models.Sale, key string, window time.Duration) (replayed bool, err error)
+	CreateSalesBatch(sales []models.Sale) error
+	CreateSalesEach(sales []models.Sale) []error
+	GetSale(id int) (*models.Sale, error)
+	GetSalesFiltered(filter models.SaleFilter) ([]models.Sale, error)
+	StreamSales(ctx context.Context, filter models.SaleFilter, fn func(models.Sale) error) error
+	CountSales(filter models.SaleFilter) (int64, error)
+	FindPotentialDuplicate(sale *models.Sale, window time.Duration) (*models.Sale, error)
+	GetRecentSales(limit int) ([]models.Sale, error)
+	GetTopSales(from, to time.Time, saleType string, limit int) ([]models.Sale, error)
+	SearchSales(term string) ([]models.Sale, error)
+	GetIncompleteSales(fields []string, defaultCategory string) ([]models.Sale, error)
+	UpdateSale(sale *models.Sale, force bool) error
+	PatchSale(id int, fields map[string]any, version int, force bool) (*models.Sale, error)
+	DeleteSale(id int, force bool) error
+	HardDeleteSale(id int, force bool) error
+	DeleteSalesInRange(from, to time.Time, force bool) (int64, error)
+	DeleteAllSales() (int64, error)
+	RestoreSale(id int) (*models.Sale, error)
+	SetSaleLocked(id int, locked bool) error
+	RecategorizeSales(from, to string) (int64, error)
+	BulkUpdateSales(filter models.SaleFilter, fields map[string]any, force bool) (int64, error)
+	GetSaleHistory(id int) ([]models.AuditEntry, error)
+	CreateAttachment(a *models.Attachment) error
+	GetAttachment(saleID, id int) (*models.Attachment, error)
+
+	GetAnalytics(from, to time.Time, percentiles ...float64) (*models.AnalyticsResponse, error)
+
+	GetAnalyticsFiltered(filter models.SaleFilter, percentiles ...float64) (*models.AnalyticsResponse, error)
+	LastModified(from, to time.Time) (time.Time, int64, error)
+	GetTimeSeries(from, to time.Time, interval, tz string) ([]models.TimeSeriesPoint, error)
+	GetNetWorth(from, to time.Time, interval, tz string) ([]models.NetWorthPoint, error)
+	GetDistribution(from, to time.Time, dimension, saleType, tz string) ([]models.DistributionBucket, error)
+	GetActiveDays(timezone string) ([]time.Time, error)
+	GetPeriods(tz string) ([]models.Period, error)
+	SumByType(saleType string, from, to time.Time) (decimal.Decimal, error)
+	GetTopCategories(from, to time.Time, saleType string, limit int) ([]models.CategoryTotal, error)
+	GetDailyExpenses(from, to time.Time, tz string) ([]models.DailyTotal, error)
+	GetPaymentMethodTotals(from, to time.Time, saleType string) ([]models.PaymentMethodTotal, error)
+	GetTagTotals(from, to time.Time, saleType string) ([]models.TagTotal, error)
+
+	ListCategories() ([]models.Category, error)
+	GetCategories() ([]models.CategoryUsage, error)
+	GetCategory(id int) (*models.Category, error)
+	CreateCategory(name string) (*models.Category, int64, error)
+	RenameCategory(id int, name string) (*models.Category, int64, error)
+	DeleteCategory(id int) error
+
+	ListBudgets(month string) ([]models.Budget, error)
+	GetBudget(id int) (*models.Budget, error)
+	CreateBudget(b *models.Budget) error
+	UpdateBudget(b *models.Budget) error
+	DeleteBudget(id int) error
+	GetBudgetStatus(month, tz string) ([]models.BudgetStatus, error)
+
+	ListRecurringRules() ([]models.RecurringRule, error)
+	GetRecurringRule(id int) (*models.RecurringRule, error)
+	CreateRecurringRule(r *models.RecurringRule) error
+	UpdateRecurringRule(r *models.RecurringRule) error
+	DeleteRecurringRule(id int) error
+
+	GetWebhookDeliveries(status string) ([]models.WebhookDelivery, error)
+	CheckIntegrity() (*models.IntegrityReport, error)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimeout puts a deadline of timeout on every request's context, so
+// context-aware work such as database calls is cancelled once a request runs
+// over. A handler that overran without writing a response gets a 503. Zero
+// disables the deadline.
+//
+// Routes in longRunning (keyed by their full path) stream responses whose
+// size is up to the client, such as exports; they get neither the deadline
+// nor the server's write timeout.
+func requestTimeout(timeout time.Duration, longRunning map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if longRunning[c.FullPath()] {
+			clearWriteDeadline(c)
+			c.Next()
+			return
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			respondTimeout(c)
+		}
+	}
+}
+
+// clearWriteDeadline lifts the http.Server WriteTimeout for the rest of the
+// request. Writers that don't support deadlines, like test recorders, are left
+// alone.
+func clearWriteDeadline(c *gin.Context) {
+	err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		c.Error(err)
+	}
+}
+
+// respondTimeout answers a request that ran out of time.
+func respondTimeout(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Request timed out", "request_id": c.GetString(requestIDKey)})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestTimeout(20*time.Millisecond, nil))
+	r.GET("/slow", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+	})
+	r.GET("/slow-error", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		respondInternalError(c, fmt.Errorf("storage.GetSales: %w", c.Request.Context().Err()))
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		_, hasDeadline := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"deadline": hasDeadline})
+	})
+	r.GET("/answered", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		<-c.Request.Context().Done()
+	})
+
+	for path, want := range map[string]int{
+		"/slow":       http.StatusServiceUnavailable,
+		"/slow-error": http.StatusServiceUnavailable,
+		"/fast":       http.StatusOK,
+		"/answered":   http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.JSONEq(t, `{"error":"Request timed out","request_id":""}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.JSONEq(t, `{"deadline":true}`, w.Body.String())
+}
+
+func TestRequestTimeout_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestTimeout(0, nil))
+	r.GET("/", func(c *gin.Context) {
+		_, hasDeadline := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"deadline": hasDeadline})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.JSONEq(t, `{"deadline":false}`, w.Body.String())
+}
+
+func TestRequestTimeout_LongRunning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestTimeout(20*time.Millisecond, map[string]bool{"/download/:id": true}))
+	handler := func(c *gin.Context) {
+		_, hasDeadline := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"deadline": hasDeadline})
+	}
+	r.GET("/download/:id", handler)
+	r.GET("/other", handler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/download/7", nil))
+	assert.JSONEq(t, `{"deadline":false}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.JSONEq(t, `{"deadline":true}`, w.Body.String())
+}
+
+func TestRequestTimeout_Router(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &models.Config{}
+	cfg.Server.RequestTimeout = 20 * time.Millisecond
+	srv := NewServer(&mockStore{schemaVersion: func() (uint, bool, error) {
+		time.Sleep(50 * time.Millisecond)
+		return 0, false, context.DeadlineExceeded
+	}}, cfg)
+
+	w := serve(srv, http.MethodGet, "/api/version", "")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Body.String())
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+var saleTypes = map[string]bool{
+	"income":  true,
+	"expense": true,
+}
+
+// normalizeSale canonicalizes and validates user-supplied fields before they
+// reach the database. Unless strict sale types are configured, the type is
+// trimmed and lowercased so "Income" is accepted as "income". A blank category
+// is replaced by the configured default, if any. Tags are canonicalized by
+// normalizeTags. The note is optional and only trimmed; the optional payment
+// method is trimmed and lowercased so "Card" and "card" total together.
+func (s *Server) normalizeSale(sale *models.Sale) error {
+	saleType := sale.Type
+	if !s.cfg.Server.StrictSaleTypes {
+		saleType = strings.ToLower(strings.TrimSpace(saleType))
+	}
+	if !saleTypes[saleType] {
+		return fmt.Errorf("unknown sale type %q: must be income or expense", sale.Type)
+	}
+	sale.Type = saleType
+
+	if !sale.Amount.IsPositive() {
+		return errors.New("amount must be greater than zero")
+	}
+	if err := validateAmountPrecision(sale.Amount); err != nil {
+		return err
+	}
+	if sale.Date.IsZero() {
+		return errors.New("date is required")
+	}
+	if strings.TrimSpace(sale.Category) == "" {
+		if s.cfg.Sales.DefaultCategory == "" {
+			return errors.New("category is required")
+		}
+		sale.Category = s.cfg.Sales.DefaultCategory
+	}
+
+	tags, err := normalizeTags(sale.Tags)
+	if err != nil {
+		return err
+	}
+	sale.Tags = tags
+	sale.Note = strings.TrimSpace(sale.Note)
+	sale.PaymentMethod = strings.ToLower(strings.TrimSpace(sale.PaymentMethod))
+	if utf8.RuneCountInString(sale.PaymentMethod) > maxPaymentMethodLength {
+		return fmt.Errorf("payment method is longer than %d characters", maxPaymentMethodLength)
+	}
+
+	if (sale.Lat == nil) != (sale.Lng == nil) {
+		return errors.New("lat and lng must be provided together")
+	}
+	if sale.Lat != nil {
+		if err := validateCoordinates(*sale.Lat, *sale.Lng); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maxAmount is the largest value the DECIMAL(10,2) amount columns hold.
+var maxAmount = decimal.RequireFromString("99999999.99")
+
+// validateAmountPrecision rejects amounts the database can't store exactly:
+// beyond maxAmount, or with more than two decimal places.
+func validateAmountPrecision(amount decimal.Decimal) error {
+	if amount.GreaterThan(maxAmount) {
+		return fmt.Errorf("amount must be at most %s", maxAmount)
+	}
+	if !amount.Equal(amount.Truncate(2)) {
+		return errors.New("amount must have at most two decimal places")
+	}
+	return nil
+}
+
+const maxPaymentMethodLength = 50
+
+const (
+	maxTags      = 20
+	maxTagLength = 50
+)
+
+// normalizeTags trims and lowercases tags, dropping blanks and repeats while
+// keeping the first-seen order. The result is never nil, so a sale without
+// tags is stored and rendered as an empty list.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	return normalized, nil
+}
+
+func validateCoordinates(lat, lng float64) error {
+	if lat < -90 || lat > 90 {
+		return fmt.Errorf("lat %v out of range [-90, 90]", lat)
+	}
+	if lng < -180 || lng > 180 {
+		return fmt.Errorf("lng %v out of range [-180, 180]", lng)
+	}
+	return nil
+}
+
+// saleProblem describes why a sale failed validation. Index is set when the
+// request held an array of sales.
+type saleProblem struct {
+	Index *int   `json:"index,omitempty"`
+	Error string `json:"error"`
+}
+
+// validateSales checks a sale or an array of sales with the same rules as
+// the create endpoints without storing anything, answering 200 with
+// {"valid":true} or 400 with every problem found.
+//
+// @Summary Validate sales without storing them
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param sales body []models.Sale true "A sale or an array of sales"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} validationResponse
+// @Security BearerAuth
+// @Router /items/validate [post]
+func (s *Server) validateSales(c *gin.Context) {
+	body, err := c.GetRawData()
+	if errors.As(err, new(*http.MaxBytesError)) {
+		respondBindError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"valid": false, "errors": []saleProblem{{Error: err.Error()}}})
+		return
+	}
+
+	var problems []saleProblem
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var sales []models.Sale
+		if err := json.Unmarshal(trimmed, &sales); err != nil {
+			problems = append(problems, saleProblem{Error: err.Error()})
+		} else if len(sales) == 0 {
+			problems = append(problems, saleProblem{Error: "batch is empty"})
+		}
+		for i := range sales {
+			if err := s.normalizeSale(&sales[i]); err != nil {
+				problems = append(problems, saleProblem{Index: &i, Error: err.Error()})
+			}
+		}
+	} else {
+		var sale models.Sale
+		if err := json.Unmarshal(trimmed, &sale); err != nil {
+			problems = append(problems, saleProblem{Error: err.Error()})
+		} else if err := s.normalizeSale(&sale); err != nil {
+			problems = append(problems, saleProblem{Error: err.Error()})
+		}
+	}
+
+	if len(problems) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"valid": false, "errors": problems})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": true})
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validSale returns a sale that passes validation, for tests to tweak.
+func validSale() models.Sale {
+	return models.Sale{
+		Type:     "expense",
+		Amount:   decimal.RequireFromString("12.50"),
+		Date:     time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Category: "Food",
+	}
+}
+
+func TestNormalizeSale(t *testing.T) {
+	srv := &Server{cfg: &models.Config{}}
+
+	t.Run("case variation accepted as canonical type", func(t *testing.T) {
+		sale := validSale()
+		sale.Type = " Income "
+		require.NoError(t, srv.normalizeSale(&sale))
+		assert.Equal(t, "income", sale.Type)
+	})
+
+	t.Run("unknown type rejected", func(t *testing.T) {
+		sale := validSale()
+		sale.Type = "transfer"
+		err := srv.normalizeSale(&sale)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `"transfer"`)
+	})
+
+	t.Run("strict mode rejects case variation", func(t *testing.T) {
+		strict := &Server{cfg: &models.Config{}}
+		strict.cfg.Server.StrictSaleTypes = true
+
+		sale := validSale()
+		sale.Type = "Income"
+		assert.Error(t, strict.normalizeSale(&sale))
+	})
+
+	t.Run("blank category gets configured default", func(t *testing.T) {
+		withDefault := &Server{cfg: &models.Config{}}
+		withDefault.cfg.Sales.DefaultCategory = "Uncategorized"
+
+		sale := validSale()
+		sale.Category = "  "
+		require.NoError(t, withDefault.normalizeSale(&sale))
+		assert.Equal(t, "Uncategorized", sale.Category)
+	})
+
+	t.Run("tags canonicalized", func(t *testing.T) {
+		sale := validSale()
+		require.NoError(t, srv.normalizeSale(&sale))
+		assert.Equal(t, []string{}, sale.Tags)
+
+		sale.Tags = []string{" Travel", "work", "", "travel "}
+		require.NoError(t, srv.normalizeSale(&sale))
+		assert.Equal(t, []string{"travel", "work"}, sale.Tags)
+
+		sale.Tags = []string{strings.Repeat("x", maxTagLength+1)}
+		assert.Error(t, srv.normalizeSale(&sale))
+	})
+
+	t.Run("note optional and trimmed", func(t *testing.T) {
+		sale := validSale()
+		require.NoError(t, srv.normalizeSale(&sale))
+		assert.Empty(t, sale.Note)
+
+		sale.Note = "  paid in cash \n"
+		require.NoError(t, srv.normalizeSale(&sale))
+		assert.Equal(t, "paid in cash", sale.Note)
+	})
+
+	t.Run("payment method optional and lowercased", func(t *testing.T) {
+		sale := validSale()
+		require.NoError(t, srv.normalizeSale(&sale))
+		assert.Empty(t, sale.PaymentMethod)
+
+		sale.PaymentMethod = " Card "
+		require.NoError(t, srv.normalizeSale(&sale))
+		assert.Equal(t, "card", sale.PaymentMethod)
+
+		sale.PaymentMethod = strings.Repeat("x", maxPaymentMethodLength+1)
+		assert.Error(t, srv.normalizeSale(&sale))
+	})
+
+	t.Run("mirrors database constraints", func(t *testing.T) {
+		for name, mutate := range map[string]func(*models.Sale){
+			"zero amount":     func(s *models.Sale) { s.Amount = decimal.Zero },
+			"amount overflow": func(s *models.Sale) { s.Amount = decimal.RequireFromString("100000000") },
+			"fractional cent": func(s *models.Sale) { s.Amount = decimal.RequireFromString("12.505") },
+			"missing date":    func(s *models.Sale) { s.Date = time.Time{} },
+			"blank category":  func(s *models.Sale) { s.Category = "  " },
+			"lat without lng": func(s *models.Sale) {
+				lat := 10.0
+				s.Lat = &lat
+			},
+		} {
+			sale := validSale()
+			mutate(&sale)
+			assert.Error(t, srv.normalizeSale(&sale), name)
+		}
+	})
+
+	t.Run("largest storable amount accepted", func(t *testing.T) {
+		sale := validSale()
+		sale.Amount = decimal.RequireFromString("99999999.990")
+		assert.NoError(t, srv.normalizeSale(&sale))
+	})
+}
+
+func TestCreateSale_AmountOverflow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	w := serve(srv, http.MethodPost, "/api/items", `{"type":"expense","amount":"1e12","date":"2024-01-15T10:30:00Z","category":"Test"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "rejected before it overflows the column")
+	assert.Contains(t, w.Body.String(), "99999999.99")
+}
+
+func TestCreateSale_UnknownType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	body := `{"type":"transfer","amount":10,"date":"2024-01-15T10:30:00Z","category":"Test"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/items", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown sale type")
+}
+
+func TestCreateSalesBatch_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := NewServer(nil, &models.Config{})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/items/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("reports index of first invalid entry", func(t *testing.T) {
+		w := post(`[
+			{"type":"income","amount":10,"date":"2024-01-15T10:30:00Z","category":"Salary"},
+			{"type":"expense","amount":0,"date":"2024-01-16T10:30:00Z","category":"Food"},
+			{"type":"bogus","amount":5,"date":"2024-01-17T10:30:00Z","category":"Food"}
+		]`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"amount must be greater than zero","index":1}`, w.Body.String())
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		w := post(`[]`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestParseNear(t *testing.T) {
+	near, err := parseNear("55.75, 37.62, 5")
+	require.NoError(t, err)
+	assert.Equal(t, &models.GeoRadius{Lat: 55.75, Lng: 37.62, RadiusKm: 5}, near)
+
+	for _, value := range []string{"55.75,37.62", "a,b,c", "91,0,5", "0,181,5", "0,0,0", "NaN,0,5", "0,NaN,5", "0,0,NaN", "0,0,Inf"} {
+		_, err := parseNear(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestValidateSales(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// A nil store proves validation never reaches the database.
+	srv := NewServer(nil, &models.Config{})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/items/validate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+	good := `{"type":"expense","amount":"12.50","date":"2024-01-15T10:30:00Z","category":"Food"}`
+
+	t.Run("valid sale", func(t *testing.T) {
+		w := post(good)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"valid":true}`, w.Body.String())
+	})
+
+	t.Run("valid batch", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post("["+good+","+good+"]").Code)
+	})
+
+	t.Run("invalid sale", func(t *testing.T) {
+		w := post(`{"type":"gift","amount":"12.50","date":"2024-01-15T10:30:00Z","category":"Food"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"valid":false`)
+		assert.Contains(t, w.Body.String(), "unknown sale type")
+	})
+
+	t.Run("batch reports every bad entry", func(t *testing.T) {
+		bad := `{"type":"expense","amount":"-1","date":"2024-01-15T10:30:00Z","category":"Food"}`
+		w := post("[" + bad + "," + good + "," + bad + "]")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		var resp struct {
+			Errors []struct {
+				Index *int `json:"index"`
+			} `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Errors, 2)
+		assert.Equal(t, 0, *resp.Errors[0].Index)
+		assert.Equal(t, 2, *resp.Errors[1].Index)
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post(`{"type":`).Code)
+		assert.Equal(t, http.StatusBadRequest, post(`[]`).Code)
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mediaTypeV1 = "application/vnd.sales.v1+json"
+	mediaTypeV2 = "application/vnd.sales.v2+json"
+)
+
+// wantsEnvelope decides between the legacy bare-array format (v1) and the
+// enveloped format (v2). An explicit vendor media type in Accept wins;
+// otherwise the configured default applies.
+func (s *Server) wantsEnvelope(c *gin.Context) bool {
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case mediaTypeV2:
+			return true
+		case mediaTypeV1:
+			return false
+		}
+	}
+	return s.cfg.Server.EnvelopeResponses
+}
+
+func (s *Server) respondSales(c *gin.Context, sales []models.Sale) {
+	s.respondSalesPage(c, sales, "")
+}
+
+// respondSalesPage is respondSales for one page of a paginated listing. A
+// non-empty next cursor is sent in the X-Next-Cursor header and, in the v2
+// envelope, as next_cursor.
+func (s *Server) respondSalesPage(c *gin.Context, sales []models.Sale, next string) {
+	c.Header("Vary", "Accept")
+	if next != "" {
+		c.Header(nextCursorHeader, next)
+	}
+
+	if !s.wantsEnvelope(c) {
+		c.JSON(http.StatusOK, sales)
+		return
+	}
+
+	if sales == nil {
+		sales = []models.Sale{}
+	}
+	c.Header("Content-Type", mediaTypeV2+"; charset=utf-8")
+	c.JSON(http.StatusOK, models.SaleList{Items: sales, Count: len(sales), NextCursor: next})
+}
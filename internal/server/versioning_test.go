@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondSales(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sales := []models.Sale{
+		{ID: 1, Type: "income", Amount: decimal.NewFromInt(100), Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Category: "Salary"},
+	}
+
+	respond := func(srv *Server, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		if accept != "" {
+			c.Request.Header.Set("Accept", accept)
+		}
+		srv.respondSales(c, sales)
+		return w
+	}
+
+	srv := &Server{cfg: &models.Config{}}
+
+	t.Run("legacy bare array by default", func(t *testing.T) {
+		w := respond(srv, "application/json")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var got []models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Len(t, got, 1)
+	})
+
+	t.Run("v2 header selects envelope", func(t *testing.T) {
+		w := respond(srv, "application/vnd.sales.v2+json")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), mediaTypeV2)
+
+		var got models.SaleList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, 1, got.Count)
+		assert.Equal(t, "Salary", got.Items[0].Category)
+	})
+
+	t.Run("v1 header overrides enveloped default", func(t *testing.T) {
+		enveloped := &Server{cfg: &models.Config{}}
+		enveloped.cfg.Server.EnvelopeResponses = true
+
+		w := respond(enveloped, "application/vnd.sales.v1+json")
+		var got []models.Sale
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+		w = respond(enveloped, "")
+		var env models.SaleList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+		assert.Equal(t, 1, env.Count)
+	})
+
+	t.Run("envelope carries the next cursor", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/items", nil)
+		c.Request.Header.Set("Accept", mediaTypeV2)
+		srv.respondSalesPage(c, sales, "abc")
+
+		var got models.SaleList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.Equal(t, "abc", got.NextCursor)
+		assert.Equal(t, "abc", w.Header().Get("X-Next-Cursor"))
+	})
+}
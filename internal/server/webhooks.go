@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"L3_6/internal/storage"
+	"L3_6/internal/webhook"
+	"L3_6/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary List webhook deliveries
+// @Tags admin
+// @Produce json
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth || AdminKey
+// @Router /admin/webhook-deliveries [get]
+func (s *Server) getWebhookDeliveries(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	deliveries, err := s.storage.GetWebhookDeliveries(status)
+	if err != nil {
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// redeliverWebhook queues a fresh round of attempts for a delivery and
+// answers 202 with it marked pending; poll the delivery list for the outcome.
+//
+// @Summary Redeliver a webhook
+// @Tags admin
+// @Produce json
+// @Param id path int true "ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Security BearerAuth || AdminKey
+// @Router /admin/webhook-deliveries/{id}/redeliver [post]
+func (s *Server) redeliverWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	delivery, err := s.webhooks.Redeliver(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		if errors.Is(err, webhook.ErrDeliveryInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Delivery is already being attempted"})
+			return
+		}
+		respondInternalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
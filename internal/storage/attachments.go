@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// CreateAttachment stores a file for a live sale, filling in its ID, size and
+// creation time. It returns ErrSaleNotFound if the sale doesn't exist or is
+// soft-deleted.
+func (s *Storage) CreateAttachment(a *models.Attachment) error {
+	const op = "storage.CreateAttachment"
+
+	query := `INSERT INTO attachments (sale_id, filename, content_type, size, data)
+		SELECT id, $2, $3, $4, $5 FROM sales WHERE id=$1 AND deleted_at IS NULL
+		RETURNING id, created_at`
+	a.Size = int64(len(a.Data))
+	err := s.db.QueryRow(context.Background(), query, a.SaleID, a.Filename, a.ContentType, a.Size, a.Data).Scan(&a.ID, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, ErrSaleNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return nil
+}
+
+// GetAttachment returns an attachment of a live sale, contents included, or
+// ErrAttachmentNotFound.
+func (s *Storage) GetAttachment(saleID, id int) (*models.Attachment, error) {
+	const op = "storage.GetAttachment"
+
+	query := `SELECT a.id, a.sale_id, a.filename, a.content_type, a.size, a.created_at, a.data
+		FROM attachments a JOIN sales s ON s.id = a.sale_id
+		WHERE a.id=$1 AND a.sale_id=$2 AND s.deleted_at IS NULL`
+	var a models.Attachment
+	err := s.db.QueryRow(context.Background(), query, id, saleID).
+		Scan(&a.ID, &a.SaleID, &a.Filename, &a.ContentType, &a.Size, &a.CreatedAt, &a.Data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, ErrAttachmentNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetSaleHistory returns the audit trail of a sale, oldest change first. The
+// trail outlives the sale, so a hard-deleted sale still has one. It fails
+// with ErrSaleNotFound only when the sale never existed.
+func (s *Storage) GetSaleHistory(id int) ([]models.AuditEntry, error) {
+	const op = "storage.GetSaleHistory"
+
+	query := `SELECT id, sale_id, action, type, amount, date, category, tags, COALESCE(note, ''), COALESCE(payment_method, ''), locked, lat, lng, version, changed_at
+		FROM sales_audit WHERE sale_id=$1 ORDER BY changed_at, id`
+	rows, err := s.db.Query(context.Background(), query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.AuditEntry, error) {
+		var e models.AuditEntry
+		err := row.Scan(&e.ID, &e.SaleID, &e.Action, &e.Type, &e.Amount, &e.Date, &e.Category, &e.Tags, &e.Note, &e.PaymentMethod, &e.Locked, &e.Lat, &e.Lng, &e.Version, &e.ChangedAt)
+		return e, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(entries) == 0 {
+		var exists bool
+		if err := s.db.QueryRow(context.Background(), `SELECT EXISTS (SELECT 1 FROM sales WHERE id=$1)`, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%s: %w", op, ErrSaleNotFound)
+		}
+	}
+
+	return entries, nil
+}
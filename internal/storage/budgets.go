@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+var ErrBudgetNotFound = errors.New("budget not found")
+
+// budgetColumns is the column list scanBudget expects. Months are stored as
+// the first day of the month and exchanged as YYYY-MM.
+const budgetColumns = `id, category, to_char(month, 'YYYY-MM'), "limit", created_at, updated_at`
+
+func scanBudget(row pgx.Row, b *models.Budget) error {
+	return row.Scan(&b.ID, &b.Category, &b.Month, &b.Limit, &b.CreatedAt, &b.UpdatedAt)
+}
+
+// ListBudgets returns budgets ordered by month and category. A non-empty
+// month (YYYY-MM) restricts the list to that month.
+func (s *Storage) ListBudgets(month string) ([]models.Budget, error) {
+	const op = "storage.ListBudgets"
+
+	query := `SELECT ` + budgetColumns + ` FROM budgets
+		WHERE $1 = '' OR month = to_date(NULLIF($1, ''), 'YYYY-MM')
+		ORDER BY month, LOWER(category)`
+	rows, err := s.db.Query(context.Background(), query, month)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	budgets := []models.Budget{}
+	for rows.Next() {
+		var b models.Budget
+		if err := scanBudget(rows, &b); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		budgets = append(budgets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return budgets, nil
+}
+
+func (s *Storage) GetBudget(id int) (*models.Budget, error) {
+	const op = "storage.GetBudget"
+
+	var b models.Budget
+	err := scanBudget(s.db.QueryRow(context.Background(), `SELECT `+budgetColumns+` FROM budgets WHERE id=$1`, id), &b)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, ErrBudgetNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &b, nil
+}
+
+// CreateBudget inserts the budget and fills in its ID and timestamps. A
+// second budget for the same category (ignoring case) and month violates
+// uniqueness.
+func (s *Storage) CreateBudget(b *models.Budget) error {
+	const op = "storage.CreateBudget"
+
+	query := `INSERT INTO budgets (category, month, "limit") VALUES ($1, to_date($2, 'YYYY-MM'), $3)
+		RETURNING ` + budgetColumns
+	if err := scanBudget(s.db.QueryRow(context.Background(), query, b.Category, b.Month, b.Limit), b); err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return nil
+}
+
+// UpdateBudget overwrites the category, month and limit of the budget with
+// b.ID and refreshes b from the stored row.
+func (s *Storage) UpdateBudget(b *models.Budget) error {
+	const op = "storage.UpdateBudget"
+
+	query := `UPDATE budgets SET category=$1, month=to_date($2, 'YYYY-MM'), "limit"=$3, updated_at=now()
+		WHERE id=$4 RETURNING ` + budgetColumns
+	err := scanBudget(s.db.QueryRow(context.Background(), query, b.Category, b.Month, b.Limit, b.ID), b)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, ErrBudgetNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteBudget(id int) error {
+	const op = "storage.DeleteBudget"
+
+	tag, err := s.db.Exec(context.Background(), `DELETE FROM budgets WHERE id=$1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrBudgetNotFound)
+	}
+
+	return nil
+}
+
+// GetBudgetStatus compares each budget for month (YYYY-MM) with the live
+// expenses in its category dated within that calendar month in the IANA time
+// zone tz.
+func (s *Storage) GetBudgetStatus(month, tz string) ([]models.BudgetStatus, error) {
+	const op = "storage.GetBudgetStatus"
+
+	query := `
+		SELECT b.id, b.category, to_char(b.month, 'YYYY-MM'), b."limit", COALESCE(SUM(s.amount), 0)
+		FROM budgets b
+		LEFT JOIN sales s ON s.deleted_at IS NULL AND s.type = 'expense'
+			AND LOWER(TRIM(s.category)) = LOWER(TRIM(b.category))
+			AND s.date >= b.month::timestamp AT TIME ZONE $2
+			AND s.date < (b.month + INTERVAL '1 month') AT TIME ZONE $2
+		WHERE b.month = to_date($1, 'YYYY-MM')
+		GROUP BY b.id
+		ORDER BY LOWER(b.category)`
+	rows, err := s.db.Query(context.Background(), query, month, tz)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	statuses := []models.BudgetStatus{}
+	for rows.Next() {
+		var st models.BudgetStatus
+		if err := rows.Scan(&st.BudgetID, &st.Category, &st.Month, &st.Limit, &st.Spent); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		st.Remaining = st.Limit.Sub(st.Spent)
+		st.Over = st.Remaining.LessThan(decimal.Zero)
+		statuses = append(statuses, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrCategoryNotFound = errors.New("category not found")
+
+// categoryQuery selects categories with the number of live sales linked to
+// each; callers append a WHERE clause before categoryGroupBy.
+const (
+	categoryQuery = `
+		SELECT c.id, c.name, COUNT(s.id), c.created_at
+		FROM categories c
+		LEFT JOIN sales s ON s.category_id = c.id AND s.deleted_at IS NULL`
+	categoryGroupBy = ` GROUP BY c.id`
+)
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func getCategory(ctx context.Context, q queryRower, id int) (*models.Category, error) {
+	var c models.Category
+	err := q.QueryRow(ctx, categoryQuery+` WHERE c.id = $1`+categoryGroupBy, id).
+		Scan(&c.ID, &c.Name, &c.SaleCount, &c.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListCategories returns all managed categories ordered by name.
+func (s *Storage) ListCategories() ([]models.Category, error) {
+	const op = "storage.ListCategories"
+
+	rows, err := s.db.Query(context.Background(), categoryQuery+categoryGroupBy+` ORDER BY LOWER(c.name)`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.SaleCount, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return categories, nil
+}
+
+// GetCategories lists the distinct category names on live sales, whether
+// or not they are managed, with how many sales use each.
+func (s *Storage) GetCategories() ([]models.CategoryUsage, error) {
+	const op = "storage.GetCategories"
+
+	query := `SELECT category, COUNT(*) FROM sales WHERE deleted_at IS NULL GROUP BY category ORDER BY category`
+	rows, err := s.db.Query(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	usage := []models.CategoryUsage{}
+	for rows.Next() {
+		var u models.CategoryUsage
+		if err := rows.Scan(&u.Name, &u.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return usage, nil
+}
+
+func (s *Storage) GetCategory(id int) (*models.Category, error) {
+	const op = "storage.GetCategory"
+
+	c, err := getCategory(context.Background(), s.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, nil
+}
+
+// CreateCategory adds a category and links the existing sales whose category
+// matches its name, rewriting them to its spelling. It also returns the
+// number of sales it linked. A name that differs from an existing one only by
+// case violates uniqueness.
+func (s *Storage) CreateCategory(name string) (*models.Category, int64, error) {
+	const op = "storage.CreateCategory"
+
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	if err := tx.QueryRow(ctx, `INSERT INTO categories (name) VALUES ($1) RETURNING id`, name).Scan(&id); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	linked, err := linkSales(ctx, tx, id, name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	c, err := getCategory(ctx, tx, id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, linked, nil
+}
+
+// RenameCategory changes a category's name and carries the new name over to
+// its linked sales. It also returns the number of sales it rewrote.
+func (s *Storage) RenameCategory(id int, name string) (*models.Category, int64, error) {
+	const op = "storage.RenameCategory"
+
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE categories SET name=$1 WHERE id=$2`, name, id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, 0, fmt.Errorf("%s: %w", op, ErrCategoryNotFound)
+	}
+	tag, err = tx.Exec(ctx, `UPDATE sales SET category=$1, version=version+1, updated_at=now() WHERE category_id=$2 AND category <> $1`, name, id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	linked, err := linkSales(ctx, tx, id, name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	c, err := getCategory(ctx, tx, id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, tag.RowsAffected() + linked, nil
+}
+
+// DeleteCategory removes a category. Its sales keep their category name but
+// are unlinked.
+func (s *Storage) DeleteCategory(id int) error {
+	const op = "storage.DeleteCategory"
+
+	tag, err := s.db.Exec(context.Background(), `DELETE FROM categories WHERE id=$1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrCategoryNotFound)
+	}
+
+	return nil
+}
+
+// linkSales attaches unlinked sales whose category matches name to the
+// category, normalizing their spelling, and returns how many it attached.
+func linkSales(ctx context.Context, tx pgx.Tx, id int, name string) (int64, error) {
+	tag, err := tx.Exec(ctx, `
+		UPDATE sales SET category_id=$1, category=$2, version=version+1, updated_at=now()
+		WHERE category_id IS NULL AND LOWER(TRIM(category)) = LOWER($2)`, id, name)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrCheckViolation  = errors.New("check constraint violated")
+	ErrUniqueViolation = errors.New("unique constraint violated")
+)
+
+// ConstraintError reports a write rejected by a database constraint. It
+// matches ErrCheckViolation or ErrUniqueViolation with errors.Is.
+type ConstraintError struct {
+	Kind       error
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Kind, e.Constraint)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classify turns constraint violations reported by Postgres into a
+// *ConstraintError and returns any other error unchanged.
+func classify(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case "23514":
+		return &ConstraintError{Kind: ErrCheckViolation, Constraint: pgErr.ConstraintName, Err: err}
+	case "23505":
+		return &ConstraintError{Kind: ErrUniqueViolation, Constraint: pgErr.ConstraintName, Err: err}
+	}
+	return err
+}
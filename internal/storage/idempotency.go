@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateSaleIdempotent creates the sale unless key was already used within
+// window, in which case it loads the sale created then into *sale and
+// reports replayed=true. Requests sharing a key are serialized, so concurrent
+// retries still create a single sale.
+func (s *Storage) CreateSaleIdempotent(sale *models.Sale, key string, window time.Duration) (replayed bool, err error) {
+	const op = "storage.CreateSaleIdempotent"
+
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM idempotency_keys WHERE created_at < now() - $1::interval`, window); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var saleID int
+	err = tx.QueryRow(ctx, `SELECT sale_id FROM idempotency_keys WHERE key=$1`, key).Scan(&saleID)
+	switch {
+	case err == nil:
+		rows, err := tx.Query(ctx, `SELECT `+saleColumns+` FROM sales WHERE id=$1`, saleID)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		sales, err := scanSales(rows)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(sales) == 0 {
+			return false, fmt.Errorf("%s: %w", op, ErrSaleNotFound)
+		}
+		*sale = sales[0]
+		return true, nil
+	case !errors.Is(err, pgx.ErrNoRows):
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.QueryRow(ctx, insertSaleQuery, insertSaleArgs(sale)...).Scan(insertSaleDest(sale)...); err != nil {
+		return false, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO idempotency_keys (key, sale_id) VALUES ($1, $2)`, key, sale.ID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return false, nil
+}
@@ -2,18 +2,27 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
 
 	"L3_6/models"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	pgxdecimal "github.com/jackc/pgx-shopspring-decimal"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func InitDB(cfg *models.Config) (*pgxpool.Pool, error) {
+// InitDB connects to the database and brings the schema up to date, unless
+// migrations are skipped, in which case it only checks the schema isn't
+// dirty. It also returns the schema version the database was left at.
+func InitDB(cfg *models.Config) (*pgxpool.Pool, uint, error) {
 	const op = "storage.initDB"
 
 	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
@@ -24,27 +33,135 @@ func InitDB(cfg *models.Config) (*pgxpool.Pool, error) {
 		cfg.Database.Name,
 	)
 
-	pool, err := pgxpool.New(context.Background(), dsn)
+	poolCfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	poolCfg.AfterConnect = registerTypes
+	applyPoolConfig(poolCfg, cfg)
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	// The database may still be starting, e.g. under Docker Compose.
+	if err := pingWithRetry(context.Background(), pool.Ping, cfg.Database.ConnectAttempts, cfg.Database.ConnectRetryDelay, time.Sleep); err != nil {
+		pool.Close()
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if cfg.Database.SkipMigrations {
+		version, dirty, err := schemaVersion(context.Background(), pool)
+		if err != nil {
+			pool.Close()
+			return nil, 0, fmt.Errorf("%s: %v", op, err)
+		}
+		slog.Info("migrations skipped", "version", version)
+		if dirty {
+			pool.Close()
+			return nil, 0, fmt.Errorf("%s: schema version %d is dirty; fix the failed migration before starting", op, version)
+		}
+		return pool, version, nil
+	}
+
+	source, err := migrationsSource(cfg.Database.MigrationsPath)
+	if err != nil {
+		pool.Close()
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
 	}
 
 	// Run migrations
-	m, err := migrate.New("file://migrations", dsn)
+	m, err := migrate.New(source, dsn)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %v", op, err)
+		pool.Close()
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
 	}
 
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return nil, fmt.Errorf("%s: %v", op, err)
+		pool.Close()
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
 	}
 
 	version, dirty, err := m.Version()
 	if err != nil && err != migrate.ErrNilVersion {
-		return nil, fmt.Errorf("%s: %v", op, err)
+		pool.Close()
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	slog.Info("migrations applied", "version", version, "dirty", dirty)
+
+	return pool, version, nil
+}
+
+// migrationsSource turns a migrations directory into a golang-migrate source
+// URL, failing if the directory doesn't exist. An empty path means
+// "migrations".
+func migrationsSource(path string) (string, error) {
+	if path == "" {
+		path = "migrations"
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("migrations path: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("migrations path %q is not a directory", path)
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+// schemaVersion reads the version golang-migrate recorded for the database.
+func schemaVersion(ctx context.Context, pool *pgxpool.Pool) (version uint, dirty bool, err error) {
+	err = pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, errors.New("no schema version recorded; apply the migrations first")
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// pingWithRetry calls ping up to attempts times (at least once), sleeping
+// between failures with exponential backoff starting at delay. It returns the
+// last error if every attempt fails.
+func pingWithRetry(ctx context.Context, ping func(context.Context) error, attempts int, delay time.Duration, sleep func(time.Duration)) error {
+	if attempts < 1 {
+		attempts = 1
 	}
 
-	log.Printf("Migrations applied successfully. Version: %d, Dirty: %t", version, dirty)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = ping(ctx); err == nil {
+			return nil
+		}
+		if attempt < attempts {
+			slog.Warn("database not ready", "attempt", attempt, "attempts", attempts, "err", err, "retry_in", delay)
+			sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return fmt.Errorf("database unreachable after %d attempts: %w", attempts, err)
+}
+
+// applyPoolConfig overrides the pool sizing with any values set in the config.
+func applyPoolConfig(poolCfg *pgxpool.Config, cfg *models.Config) {
+	if cfg.Database.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.Database.MaxConns
+	}
+	if cfg.Database.MinConns > 0 {
+		poolCfg.MinConns = cfg.Database.MinConns
+	}
+	if cfg.Database.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = cfg.Database.MaxConnLifetime
+	}
+}
 
-	return pool, nil
+// registerTypes teaches each new connection to encode and decode
+// decimal.Decimal as NUMERIC.
+func registerTypes(ctx context.Context, conn *pgx.Conn) error {
+	pgxdecimal.Register(conn.TypeMap())
+	return nil
 }
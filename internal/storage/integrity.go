@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"L3_6/models"
+)
+
+// integrityChecks are conditions that no stored sale should satisfy. Each is
+// reported by name with the number of matching rows.
+var integrityChecks = []struct {
+	name string
+	cond string
+}{
+	{"future_date", `date > now()`},
+	// amount is DECIMAL(10,2), so this is the largest storable value.
+	{"amount_at_limit", `amount >= 99999999.99`},
+	{"blank_category", `TRIM(category) = ''`},
+	{"partial_location", `(lat IS NULL) <> (lng IS NULL)`},
+	{"updated_before_created", `updated_at < created_at`},
+	// Once categories are managed, every sale should be linked to one, under
+	// its current name.
+	{"unlisted_category", `category_id IS NULL AND EXISTS (SELECT 1 FROM categories)`},
+	{"category_mismatch", `category_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM categories c WHERE c.id = category_id AND c.name = category)`},
+	// Tags are stored trimmed, lowercased and without repeats.
+	{"malformed_tags", `EXISTS (SELECT 1 FROM unnest(tags) t WHERE t = '' OR t <> LOWER(TRIM(t))) OR cardinality(tags) <> (SELECT COUNT(DISTINCT t) FROM unnest(tags) t)`},
+}
+
+// CheckIntegrity runs every integrity check in a single scan of sales.
+func (s *Storage) CheckIntegrity() (*models.IntegrityReport, error) {
+	const op = "storage.CheckIntegrity"
+
+	selects := make([]string, len(integrityChecks))
+	for i, check := range integrityChecks {
+		selects[i] = fmt.Sprintf("COUNT(*) FILTER (WHERE %s)", check.cond)
+	}
+	query := `SELECT ` + strings.Join(selects, ", ") + `, now() FROM sales`
+
+	counts := make([]int, len(integrityChecks))
+	dest := make([]any, 0, len(counts)+1)
+	for i := range counts {
+		dest = append(dest, &counts[i])
+	}
+	var checkedAt time.Time
+	dest = append(dest, &checkedAt)
+
+	if err := s.db.QueryRow(context.Background(), query).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := &models.IntegrityReport{
+		Issues:    make(map[string]int, len(integrityChecks)),
+		Healthy:   true,
+		CheckedAt: checkedAt,
+	}
+	for i, check := range integrityChecks {
+		report.Issues[check.name] = counts[i]
+		if counts[i] > 0 {
+			report.Healthy = false
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrRecurringRuleNotFound = errors.New("recurring rule not found")
+
+// RecurringIntervals are the supported repeat intervals of a recurring rule.
+var RecurringIntervals = map[string]bool{
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+}
+
+const recurringRuleColumns = `id, type, amount, category, interval, next_date, created_at, updated_at`
+
+func scanRecurringRule(row pgx.Row, r *models.RecurringRule) error {
+	return row.Scan(&r.ID, &r.Type, &r.Amount, &r.Category, &r.Interval, &r.NextDate, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// ListRecurringRules returns all recurring rules, soonest first.
+func (s *Storage) ListRecurringRules() ([]models.RecurringRule, error) {
+	const op = "storage.ListRecurringRules"
+
+	rows, err := s.db.Query(context.Background(), `SELECT `+recurringRuleColumns+` FROM recurring_rules ORDER BY next_date, id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	rules := []models.RecurringRule{}
+	for rows.Next() {
+		var r models.RecurringRule
+		if err := scanRecurringRule(rows, &r); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rules, nil
+}
+
+func (s *Storage) GetRecurringRule(id int) (*models.RecurringRule, error) {
+	const op = "storage.GetRecurringRule"
+
+	var r models.RecurringRule
+	err := scanRecurringRule(s.db.QueryRow(context.Background(), `SELECT `+recurringRuleColumns+` FROM recurring_rules WHERE id=$1`, id), &r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, ErrRecurringRuleNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &r, nil
+}
+
+// CreateRecurringRule inserts the rule and fills in its ID and timestamps.
+// Its first sale will be dated r.NextDate.
+func (s *Storage) CreateRecurringRule(r *models.RecurringRule) error {
+	const op = "storage.CreateRecurringRule"
+
+	query := `INSERT INTO recurring_rules (type, amount, category, interval, next_date, anchor_date)
+		VALUES ($1, $2, $3, $4, $5, $5) RETURNING ` + recurringRuleColumns
+	if err := scanRecurringRule(s.db.QueryRow(context.Background(), query, r.Type, r.Amount, r.Category, r.Interval, r.NextDate), r); err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return nil
+}
+
+// UpdateRecurringRule overwrites the rule with r.ID. The schedule restarts
+// from r.NextDate; sales already materialized are left alone.
+func (s *Storage) UpdateRecurringRule(r *models.RecurringRule) error {
+	const op = "storage.UpdateRecurringRule"
+
+	query := `UPDATE recurring_rules
+		SET type=$1, amount=$2, category=$3, interval=$4, next_date=$5, anchor_date=$5, occurrences=0, updated_at=now()
+		WHERE id=$6 RETURNING ` + recurringRuleColumns
+	err := scanRecurringRule(s.db.QueryRow(context.Background(), query, r.Type, r.Amount, r.Category, r.Interval, r.NextDate, r.ID), r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, ErrRecurringRuleNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return nil
+}
+
+// DeleteRecurringRule removes the rule. Sales it created are kept.
+func (s *Storage) DeleteRecurringRule(id int) error {
+	const op = "storage.DeleteRecurringRule"
+
+	tag, err := s.db.Exec(context.Background(), `DELETE FROM recurring_rules WHERE id=$1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrRecurringRuleNotFound)
+	}
+
+	return nil
+}
+
+// materializeSaleQuery inserts one occurrence of a rule as a sale, returning
+// it. The (recurring_rule_id, date) unique index turns a repeated occurrence
+// into a no-op returning no row, so replaying a run never creates duplicates.
+var materializeSaleQuery = fmt.Sprintf(`INSERT INTO sales (type, amount, date, category, category_id, recurring_rule_id)
+	VALUES ($1, $2, $3, `+categoryNameExpr+`, `+categoryIDExpr+`, $5)
+	ON CONFLICT (recurring_rule_id, date) WHERE recurring_rule_id IS NOT NULL DO NOTHING
+	RETURNING `+saleColumns, 4)
+
+// MaterializeDueRules creates a sale for every occurrence of every rule dated
+// at or before now, advances the rules past now and returns the sales it
+// created. Calendar arithmetic happens in loc, so a monthly rule stays on the
+// same local day. Each rule is handled in its own transaction and rows being
+// handled by a concurrent run are skipped. On error, the sales of the rules
+// already committed are still returned.
+func (s *Storage) MaterializeDueRules(now time.Time, loc *time.Location) ([]models.Sale, error) {
+	const op = "storage.MaterializeDueRules"
+
+	rows, err := s.db.Query(context.Background(), `SELECT id FROM recurring_rules WHERE next_date <= $1 ORDER BY id`, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var created []models.Sale
+	for _, id := range ids {
+		sales, err := s.materializeRule(id, now, loc)
+		if err != nil {
+			return created, fmt.Errorf("%s: rule %d: %w", op, id, err)
+		}
+		created = append(created, sales...)
+	}
+
+	return created, nil
+}
+
+func (s *Storage) materializeRule(id int, now time.Time, loc *time.Location) ([]models.Sale, error) {
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		r           models.RecurringRule
+		anchor      time.Time
+		occurrences int
+	)
+	err = tx.QueryRow(ctx, `SELECT type, amount, category, interval, anchor_date, occurrences
+		FROM recurring_rules WHERE id=$1 AND next_date <= $2 FOR UPDATE SKIP LOCKED`, id, now).
+		Scan(&r.Type, &r.Amount, &r.Category, &r.Interval, &anchor, &occurrences)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Already advanced, deleted or locked by another run.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var created []models.Sale
+	next := occurrence(anchor, r.Interval, occurrences, loc)
+	for !next.After(now) {
+		rows, err := tx.Query(ctx, materializeSaleQuery, r.Type, r.Amount, next, r.Category, id)
+		if err != nil {
+			return nil, classify(err)
+		}
+		sales, err := scanSales(rows)
+		if err != nil {
+			return nil, classify(err)
+		}
+		created = append(created, sales...)
+		occurrences++
+		next = occurrence(anchor, r.Interval, occurrences, loc)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE recurring_rules SET next_date=$1, occurrences=$2, updated_at=now() WHERE id=$3`, next, occurrences, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// occurrence returns the n-th repetition of anchor, counting anchor itself as
+// the zeroth. Monthly and yearly repetitions that would land past the end of
+// a month are clamped to its last day, so a rule anchored on the 31st falls
+// on 29 February and then on 31 March again.
+func occurrence(anchor time.Time, interval string, n int, loc *time.Location) time.Time {
+	anchor = anchor.In(loc)
+	switch interval {
+	case "daily":
+		return anchor.AddDate(0, 0, n)
+	case "weekly":
+		return anchor.AddDate(0, 0, 7*n)
+	case "yearly":
+		n *= 12
+	}
+
+	year, month, day := anchor.Date()
+	first := time.Date(year, month+time.Month(n), 1, anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), loc)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -2,106 +2,1321 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"L3_6/models"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrSaleNotFound = errors.New("sale not found")
+	ErrSaleLocked   = errors.New("sale is locked")
+	// ErrVersionConflict means the sale changed since the caller read it.
+	ErrVersionConflict = errors.New("sale was modified concurrently")
 )
 
 type Storage struct {
 	db *pgxpool.Pool
 }
 
-func NewStorage(db *pgxpool.Pool) *Storage {
-	return &Storage{db: db}
+func NewStorage(db *pgxpool.Pool) *Storage {
+	return &Storage{db: db}
+}
+
+// Ping checks that the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	const op = "storage.Ping"
+
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PoolStat returns a snapshot of the connection pool statistics.
+func (s *Storage) PoolStat() *pgxpool.Stat {
+	return s.db.Stat()
+}
+
+// SchemaVersion returns the migration version recorded in the database and
+// whether the last migration left it dirty.
+func (s *Storage) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	const op = "storage.SchemaVersion"
+
+	version, dirty, err := schemaVersion(ctx, s.db)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return version, dirty, nil
+}
+
+func (s *Storage) CreateSale(sale *models.Sale) error {
+	const op = "storage.CreateSale"
+
+	err := s.db.QueryRow(context.Background(), insertSaleQuery, insertSaleArgs(sale)...).Scan(insertSaleDest(sale)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return nil
+}
+
+// CreateSalesBatch inserts all sales in one transaction, assigning their IDs
+// in place. Either every sale is stored or none is.
+func (s *Storage) CreateSalesBatch(sales []models.Sale) error {
+	const op = "storage.CreateSalesBatch"
+
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for i := range sales {
+		batch.Queue(insertSaleQuery, insertSaleArgs(&sales[i])...)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for i := range sales {
+		if err := results.QueryRow().Scan(insertSaleDest(&sales[i])...); err != nil {
+			results.Close()
+			return fmt.Errorf("%s: sale %d: %w", op, i, classify(err))
+		}
+	}
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateSalesEach inserts each sale on its own rather than in a shared
+// transaction, so a failing sale doesn't keep the others out. IDs are
+// assigned in place to the sales stored; the error for sales[i], or nil, is
+// at index i of the result.
+func (s *Storage) CreateSalesEach(sales []models.Sale) []error {
+	errs := make([]error, len(sales))
+	for i := range sales {
+		errs[i] = s.CreateSale(&sales[i])
+	}
+	return errs
+}
+
+// categoryNameExpr and categoryIDExpr resolve a category name parameter
+// against the categories table, case-insensitively. A matching category
+// supplies its canonical spelling and ID; otherwise the name is kept as given
+// and the sale stays unlinked.
+const (
+	categoryNameExpr = `COALESCE((SELECT name FROM categories WHERE LOWER(name) = LOWER(TRIM($%[1]d))), $%[1]d)`
+	categoryIDExpr   = `(SELECT id FROM categories WHERE LOWER(name) = LOWER(TRIM($%[1]d)))`
+)
+
+// categorySet renders the SET assignments for a category name parameter.
+func categorySet(n int) string {
+	return fmt.Sprintf("category="+categoryNameExpr+", category_id="+categoryIDExpr, n)
+}
+
+var insertSaleQuery = fmt.Sprintf(`INSERT INTO sales (type, amount, date, category, category_id, locked, lat, lng, tags, note, payment_method) VALUES ($1, $2, $3, `+categoryNameExpr+`, `+categoryIDExpr+`, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, '')) RETURNING id, category, category_id, version, created_at, updated_at`, 4)
+
+func insertSaleArgs(sale *models.Sale) []any {
+	return []any{sale.Type, sale.Amount, sale.Date, sale.Category, sale.Locked, sale.Lat, sale.Lng, tagsArg(sale.Tags), sale.Note, sale.PaymentMethod}
+}
+
+// tagsArg passes tags as a text[] parameter. pgx encodes a nil slice as NULL,
+// which the NOT NULL tags column rejects, so nil becomes an empty array.
+func tagsArg(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// insertSaleDest lists the fields insertSaleQuery's RETURNING clause fills.
+func insertSaleDest(sale *models.Sale) []any {
+	return []any{&sale.ID, &sale.Category, &sale.CategoryID, &sale.Version, &sale.CreatedAt, &sale.UpdatedAt}
+}
+
+func (s *Storage) GetSales() ([]models.Sale, error) {
+	return s.GetSalesFiltered(models.SaleFilter{})
+}
+
+// GetSalesFiltered lists sales matching the filter, most recent first.
+func (s *Storage) GetSalesFiltered(filter models.SaleFilter) ([]models.Sale, error) {
+	const op = "storage.GetSales"
+
+	query, args, err := salesQuery(filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scan := scanSales
+	if filter.Balance {
+		scan = scanSalesWithBalance
+	}
+	sales, err := scan(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sales, nil
+}
+
+// StreamSales calls fn for each sale matching the filter, in the order
+// GetSalesFiltered lists them, decoding rows as they arrive from the database
+// instead of collecting them first, so memory use doesn't grow with the
+// result. The query is cancelled with ctx; an error from fn stops the
+// iteration and is returned as is.
+func (s *Storage) StreamSales(ctx context.Context, filter models.SaleFilter, fn func(models.Sale) error) error {
+	const op = "storage.StreamSales"
+
+	query, args, err := salesQuery(filter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sale models.Sale
+		dest := saleDest(&sale)
+		if filter.Balance {
+			dest = append(dest, &sale.RunningBalance)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := fn(sale); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// salesQuery renders the SELECT behind GetSalesFiltered and StreamSales.
+// With filter.Balance each row has a trailing running_balance column.
+func salesQuery(filter models.SaleFilter) (string, []any, error) {
+	orderBy, err := buildSaleOrder(filter)
+	if err != nil {
+		return "", nil, err
+	}
+	where, args := buildSaleFilter(filter)
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		orderBy += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Balance {
+		return `SELECT ` + saleColumns + `, running_balance FROM ` + balancedSales + where + orderBy, args, nil
+	}
+	return `SELECT ` + saleColumns + ` FROM sales` + where + orderBy, args, nil
+}
+
+// CountSales counts the sales matching the filter, using the same conditions
+// as GetSalesFiltered. Sorting and paging fields are ignored.
+func (s *Storage) CountSales(filter models.SaleFilter) (int64, error) {
+	const op = "storage.CountSales"
+
+	filter.After = nil
+	where, args := buildSaleFilter(filter)
+
+	var count int64
+	if err := s.db.QueryRow(context.Background(), `SELECT COUNT(*) FROM sales`+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// balancedSales stands in for the sales table with a running_balance column:
+// the ledger balance after each live sale in chronological order, summing
+// signed amounts as in models.SignedAmount. It is computed before any filter
+// applies, so a filtered listing still shows balances over the whole ledger.
+const balancedSales = `(
+	SELECT *, SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END) OVER (ORDER BY date, id) AS running_balance
+	FROM sales WHERE deleted_at IS NULL
+) AS sales`
+
+// FindPotentialDuplicate returns the live sale closest in date to sale that
+// has the same type, amount and category (ignoring case) and a date within
+// window of it, or nil if there is none.
+func (s *Storage) FindPotentialDuplicate(sale *models.Sale, window time.Duration) (*models.Sale, error) {
+	const op = "storage.FindPotentialDuplicate"
+
+	query := `SELECT ` + saleColumns + ` FROM sales
+		WHERE deleted_at IS NULL AND type = $1 AND amount = $2
+			AND LOWER(TRIM(category)) = LOWER(TRIM($3))
+			AND date BETWEEN $4::timestamptz - $5::interval AND $4::timestamptz + $5::interval
+		ORDER BY ABS(EXTRACT(EPOCH FROM date - $4::timestamptz)), id
+		LIMIT 1`
+	rows, err := s.db.Query(context.Background(), query, sale.Type, sale.Amount, sale.Category, sale.Date, window)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(sales) == 0 {
+		return nil, nil
+	}
+
+	return &sales[0], nil
+}
+
+// SearchSales lists sales whose category or note contains term, ignoring
+// case. The term is matched literally; % and _ are not wildcards.
+func (s *Storage) SearchSales(term string) ([]models.Sale, error) {
+	const op = "storage.SearchSales"
+
+	query := `SELECT ` + saleColumns + ` FROM sales WHERE deleted_at IS NULL AND (category ILIKE '%' || $1 || '%' OR note ILIKE '%' || $1 || '%') ORDER BY date DESC`
+	rows, err := s.db.Query(context.Background(), query, likeEscaper.Replace(term))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sales, nil
+}
+
+// GetRecentSales lists the limit most recent live sales, newest first, with
+// ties broken by the later-created sale first.
+func (s *Storage) GetRecentSales(limit int) ([]models.Sale, error) {
+	const op = "storage.GetRecentSales"
+
+	query := `SELECT ` + saleColumns + ` FROM sales WHERE deleted_at IS NULL ORDER BY date DESC, id DESC LIMIT $1`
+	rows, err := s.db.Query(context.Background(), query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sales, nil
+}
+
+// GetTopSales lists the limit largest sales of saleType dated in [from, to],
+// by amount, with ties broken by the older sale first.
+func (s *Storage) GetTopSales(from, to time.Time, saleType string, limit int) ([]models.Sale, error) {
+	const op = "storage.GetTopSales"
+
+	query := `SELECT ` + saleColumns + ` FROM sales WHERE type = $1 AND date BETWEEN $2 AND $3 AND deleted_at IS NULL ORDER BY amount DESC, id LIMIT $4`
+	rows, err := s.db.Query(context.Background(), query, saleType, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sales, nil
+}
+
+// likeEscaper escapes LIKE metacharacters using the default backslash escape.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// IncompleteFields maps a reportable field name to the condition that makes a
+// row count as missing it. $1 stands for the configured default category.
+var IncompleteFields = map[string]string{
+	"category": `(TRIM(category) = '' OR LOWER(TRIM(category)) = LOWER($1))`,
+	"location": `(lat IS NULL OR lng IS NULL)`,
+	"note":     `(note IS NULL OR TRIM(note) = '')`,
+}
+
+// GetIncompleteSales lists sales missing any of the given fields, which must
+// be keys of IncompleteFields. A sale in defaultCategory counts as missing its
+// category.
+func (s *Storage) GetIncompleteSales(fields []string, defaultCategory string) ([]models.Sale, error) {
+	const op = "storage.GetIncompleteSales"
+
+	var conds []string
+	for _, field := range fields {
+		cond, ok := IncompleteFields[field]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown field %q", op, field)
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("%s: no fields given", op)
+	}
+
+	query := `SELECT ` + saleColumns + ` FROM sales WHERE deleted_at IS NULL AND (` + strings.Join(conds, " OR ") + `) ORDER BY date DESC`
+	var args []any
+	if strings.Contains(query, "$1") {
+		args = append(args, defaultCategory)
+	}
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sales, nil
+}
+
+// saleColumns is the column list scanSales expects. A missing note or payment
+// method reads as an empty string.
+const saleColumns = `id, type, amount, date, category, category_id, tags, COALESCE(note, '') AS note, COALESCE(payment_method, '') AS payment_method, locked, lat, lng, version, created_at, updated_at`
+
+func scanSales(rows pgx.Rows) ([]models.Sale, error) {
+	defer rows.Close()
+
+	var sales []models.Sale
+	for rows.Next() {
+		var sale models.Sale
+		if err := rows.Scan(saleDest(&sale)...); err != nil {
+			return nil, err
+		}
+		sales = append(sales, sale)
+	}
+
+	return sales, rows.Err()
+}
+
+// scanSalesWithBalance is scanSales for rows with a trailing running_balance.
+func scanSalesWithBalance(rows pgx.Rows) ([]models.Sale, error) {
+	defer rows.Close()
+
+	var sales []models.Sale
+	for rows.Next() {
+		var sale models.Sale
+		if err := rows.Scan(append(saleDest(&sale), &sale.RunningBalance)...); err != nil {
+			return nil, err
+		}
+		sales = append(sales, sale)
+	}
+
+	return sales, rows.Err()
+}
+
+// saleDest returns scan destinations for saleColumns.
+func saleDest(sale *models.Sale) []any {
+	return []any{&sale.ID, &sale.Type, &sale.Amount, &sale.Date, &sale.Category, &sale.CategoryID, &sale.Tags, &sale.Note, &sale.PaymentMethod, &sale.Locked, &sale.Lat, &sale.Lng, &sale.Version, &sale.CreatedAt, &sale.UpdatedAt}
+}
+
+// haversineCond keeps rows whose great-circle distance in kilometres from
+// ($lat, $lng) is within $radius. Rows without coordinates never match. The
+// ASIN argument is capped at 1: rounding can push it just past 1 for nearly
+// antipodal points, which Postgres rejects as out of range.
+const haversineCond = `6371 * 2 * ASIN(LEAST(1, SQRT(
+	POWER(SIN(RADIANS(lat - $%[1]d) / 2), 2) +
+	COS(RADIANS($%[1]d)) * COS(RADIANS(lat)) * POWER(SIN(RADIANS(lng - $%[2]d) / 2), 2)
+))) <= $%[3]d`
+
+// SortableColumns are the columns a sales listing may be ordered by.
+var SortableColumns = map[string]bool{
+	"date":     true,
+	"amount":   true,
+	"category": true,
+	"id":       true,
+}
+
+// buildSaleOrder renders the filter's sort as an ORDER BY clause. Only
+// whitelisted column names are interpolated; id breaks ties so paging through
+// equal values is stable.
+func buildSaleOrder(filter models.SaleFilter) (string, error) {
+	column := filter.Sort
+	if column == "" {
+		column = "date"
+	}
+	if !SortableColumns[column] {
+		return "", fmt.Errorf("unknown sort column %q", column)
+	}
+	if filter.After != nil && column != "date" {
+		return "", fmt.Errorf("a cursor requires sorting by date, not %q", column)
+	}
+
+	dir := "DESC"
+	if filter.Ascending {
+		dir = "ASC"
+	}
+	if column == "id" {
+		return " ORDER BY id " + dir, nil
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", column, dir, dir), nil
+}
+
+// buildSaleFilter renders the filter as a WHERE clause together with its
+// positional arguments. Soft-deleted sales are always excluded.
+func buildSaleFilter(filter models.SaleFilter) (string, []any) {
+	conds := []string{"deleted_at IS NULL"}
+	var args []any
+
+	switch {
+	case filter.From != nil && filter.To != nil:
+		args = append(args, *filter.From, *filter.To)
+		conds = append(conds, fmt.Sprintf("date BETWEEN $%d AND $%d", len(args)-1, len(args)))
+	case filter.From != nil:
+		args = append(args, *filter.From)
+		conds = append(conds, fmt.Sprintf("date >= $%d", len(args)))
+	case filter.To != nil:
+		args = append(args, *filter.To)
+		conds = append(conds, fmt.Sprintf("date <= $%d", len(args)))
+	}
+
+	if filter.Near != nil {
+		args = append(args, filter.Near.Lat, filter.Near.Lng, filter.Near.RadiusKm)
+		conds = append(conds, fmt.Sprintf(haversineCond, len(args)-2, len(args)-1, len(args)))
+	}
+
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conds = append(conds, fmt.Sprintf("type = $%d", len(args)))
+	}
+
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		conds = append(conds, fmt.Sprintf("LOWER(TRIM(category)) = LOWER(TRIM($%d))", len(args)))
+	}
+
+	if filter.PaymentMethod != "" {
+		args = append(args, filter.PaymentMethod)
+		conds = append(conds, fmt.Sprintf("LOWER(payment_method) = LOWER(TRIM($%d))", len(args)))
+	}
+
+	if filter.Uncategorized != nil {
+		args = append(args, *filter.Uncategorized)
+		conds = append(conds, fmt.Sprintf("(TRIM(category) = '' OR LOWER(TRIM(category)) = LOWER($%d))", len(args)))
+	}
+
+	if len(filter.Tags) > 0 {
+		args = append(args, filter.Tags)
+		conds = append(conds, fmt.Sprintf("tags @> $%d", len(args)))
+	}
+
+	// Row comparison matches the (date, id) ordering, so the next page
+	// starts right after the cursor even when dates tie.
+	if filter.After != nil {
+		cmp := "<"
+		if filter.Ascending {
+			cmp = ">"
+		}
+		args = append(args, filter.After.Date, filter.After.ID)
+		conds = append(conds, fmt.Sprintf("(date, id) %s ($%d, $%d)", cmp, len(args)-1, len(args)))
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// UpdateSale overwrites the sale with the given ID and increments its
+// version. Locked sales are only updated when force is set; otherwise
+// ErrSaleLocked is returned. A non-zero sale.Version must match the stored
+// version, or ErrVersionConflict is returned.
+func (s *Storage) UpdateSale(sale *models.Sale, force bool) error {
+	const op = "storage.UpdateSale"
+
+	query := `UPDATE sales SET type=$1, amount=$2, date=$3, ` + categorySet(4) + `, lat=$5, lng=$6, tags=$7, note=NULLIF($8, ''), payment_method=NULLIF($9, ''), version=version+1, updated_at=now()
+		WHERE id=$10 AND deleted_at IS NULL AND (NOT locked OR $11) AND ($12 = 0 OR version = $12)
+		RETURNING locked, category, category_id, version, created_at, updated_at`
+	err := s.db.QueryRow(context.Background(), query, sale.Type, sale.Amount, sale.Date, sale.Category, sale.Lat, sale.Lng, tagsArg(sale.Tags), sale.Note, sale.PaymentMethod, sale.ID, force, sale.Version).
+		Scan(&sale.Locked, &sale.Category, &sale.CategoryID, &sale.Version, &sale.CreatedAt, &sale.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.checkWriteConflict(op, sale.ID, force, sale.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return nil
+}
+
+// GetSale returns the sale with the given ID, or ErrSaleNotFound.
+func (s *Storage) GetSale(id int) (*models.Sale, error) {
+	const op = "storage.GetSale"
+
+	rows, err := s.db.Query(context.Background(), `SELECT `+saleColumns+` FROM sales WHERE id=$1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(sales) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrSaleNotFound)
+	}
+
+	return &sales[0], nil
+}
+
+// PatchableColumns lists the sale columns PatchSale may set.
+var PatchableColumns = map[string]bool{
+	"type":           true,
+	"amount":         true,
+	"date":           true,
+	"category":       true,
+	"lat":            true,
+	"lng":            true,
+	"tags":           true,
+	"note":           true,
+	"payment_method": true,
+}
+
+// PatchSale sets only the given columns on a sale, increments its version and
+// returns the updated row. Keys must be in PatchableColumns. Locked sales are
+// only changed when force is set; a non-zero version must match the stored
+// one; a missing sale yields ErrSaleNotFound.
+func (s *Storage) PatchSale(id int, fields map[string]any, version int, force bool) (*models.Sale, error) {
+	const op = "storage.PatchSale"
+
+	sets, args, err := patchSets(fields, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	args = append(args, id, force, version)
+
+	query := fmt.Sprintf(`UPDATE sales SET %s WHERE id=$%d AND deleted_at IS NULL AND (NOT locked OR $%d) AND ($%[4]d = 0 OR version = $%[4]d) RETURNING `+saleColumns,
+		strings.Join(sets, ", "), len(args)-2, len(args)-1, len(args))
+	rows, err := s.db.Query(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, classify(err))
+	}
+	if len(sales) == 0 {
+		if err := s.checkWriteConflict(op, id, force, version); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", op, ErrSaleNotFound)
+	}
+
+	return &sales[0], nil
+}
+
+// patchSets renders SET assignments for fields, whose keys must be in
+// PatchableColumns, appending their values to args. Columns are taken in
+// sorted order so the query text is stable. The version bump and updated_at
+// are always included.
+func patchSets(fields map[string]any, args []any) ([]string, []any, error) {
+	if len(fields) == 0 {
+		return nil, nil, errors.New("no fields given")
+	}
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if !PatchableColumns[column] {
+			return nil, nil, fmt.Errorf("unknown column %q", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, 0, len(columns)+2)
+	for _, column := range columns {
+		args = append(args, fields[column])
+		switch column {
+		case "category":
+			sets = append(sets, categorySet(len(args)))
+		case "note", "payment_method":
+			sets = append(sets, fmt.Sprintf("%s=NULLIF($%d, '')", column, len(args)))
+		default:
+			sets = append(sets, fmt.Sprintf("%s=$%d", column, len(args)))
+		}
+	}
+	sets = append(sets, "version=version+1", "updated_at=now()")
+	return sets, args, nil
+}
+
+// BulkUpdateSales sets the given columns on every live sale matching filter
+// in one statement and returns how many sales changed. Keys must be in
+// PatchableColumns. The filter must narrow the match by date, type, category
+// or tags, so a mistake can't rewrite the whole table. Locked sales are
+// skipped unless force is set.
+func (s *Storage) BulkUpdateSales(filter models.SaleFilter, fields map[string]any, force bool) (int64, error) {
+	const op = "storage.BulkUpdateSales"
+
+	filter = models.SaleFilter{From: filter.From, To: filter.To, Type: filter.Type, Category: filter.Category, Tags: filter.Tags}
+	where, args := buildSaleFilter(filter)
+	if len(args) == 0 {
+		return 0, fmt.Errorf("%s: no filter given", op)
+	}
+	sets, args, err := patchSets(fields, args)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	args = append(args, force)
+
+	query := fmt.Sprintf(`UPDATE sales SET %s%s AND (NOT locked OR $%d)`, strings.Join(sets, ", "), where, len(args))
+	tag, err := s.db.Exec(context.Background(), query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteSale soft-deletes the sale with the given ID by stamping deleted_at;
+// RestoreSale undoes it. Locked sales are only deleted when force is set;
+// otherwise ErrSaleLocked is returned.
+func (s *Storage) DeleteSale(id int, force bool) error {
+	const op = "storage.DeleteSale"
+
+	query := `UPDATE sales SET deleted_at=now() WHERE id=$1 AND deleted_at IS NULL AND (NOT locked OR $2)`
+	tag, err := s.db.Exec(context.Background(), query, id, force)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return s.checkLocked(op, id)
+	}
+
+	return nil
 }
 
-func (s *Storage) CreateSale(sale *models.Sale) error {
-	const op = "storage.CreateSale"
+// DeleteSalesInRange soft-deletes every live sale dated in [from, to] and
+// returns how many were deleted. Locked sales are skipped unless force is set.
+func (s *Storage) DeleteSalesInRange(from, to time.Time, force bool) (int64, error) {
+	const op = "storage.DeleteSalesInRange"
+
+	query := `UPDATE sales SET deleted_at=now() WHERE date BETWEEN $1 AND $2 AND deleted_at IS NULL AND (NOT locked OR $3)`
+	tag, err := s.db.Exec(context.Background(), query, from, to, force)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// DeleteAllSales permanently removes every sale, soft-deleted or not, along
+// with their audit trail, attachments and idempotency keys, and restarts the
+// ID sequences. It returns how many sales there were. It is meant for
+// resetting demo and test data.
+func (s *Storage) DeleteAllSales() (int64, error) {
+	const op = "storage.DeleteAllSales"
+
+	ctx := context.Background()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock first so the count matches what the truncate removes.
+	if _, err := tx.Exec(ctx, `LOCK TABLE sales IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	var count int64
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM sales`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.Exec(ctx, `TRUNCATE sales, sales_audit, attachments, idempotency_keys RESTART IDENTITY`); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// HardDeleteSale permanently removes the sale with the given ID, whether or
+// not it is soft-deleted. Locking applies as for DeleteSale.
+func (s *Storage) HardDeleteSale(id int, force bool) error {
+	const op = "storage.HardDeleteSale"
 
-	query := `INSERT INTO sales (type, amount, date, category) VALUES ($1, $2, $3, $4) RETURNING id`
-	err := s.db.QueryRow(context.Background(), query, sale.Type, sale.Amount, sale.Date, sale.Category).Scan(&sale.ID)
+	query := `DELETE FROM sales WHERE id=$1 AND (NOT locked OR $2)`
+	tag, err := s.db.Exec(context.Background(), query, id, force)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if tag.RowsAffected() == 0 {
+		return s.checkLockedAny(op, id)
+	}
 
 	return nil
 }
 
-func (s *Storage) GetSales() ([]models.Sale, error) {
-	const op = "storage.GetSales"
+// RestoreSale clears deleted_at on a soft-deleted sale and returns it. It
+// returns ErrSaleNotFound if there is no deleted sale with that ID.
+func (s *Storage) RestoreSale(id int) (*models.Sale, error) {
+	const op = "storage.RestoreSale"
 
-	query := `SELECT id, type, amount, date, category FROM sales ORDER BY date DESC`
-	rows, err := s.db.Query(context.Background(), query)
+	query := `UPDATE sales SET deleted_at=NULL, updated_at=now() WHERE id=$1 AND deleted_at IS NOT NULL RETURNING ` + saleColumns
+	rows, err := s.db.Query(context.Background(), query, id)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	defer rows.Close()
-
-	var sales []models.Sale
-	for rows.Next() {
-		var sale models.Sale
-		err := rows.Scan(&sale.ID, &sale.Type, &sale.Amount, &sale.Date, &sale.Category)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		sales = append(sales, sale)
+	sales, err := scanSales(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(sales) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrSaleNotFound)
 	}
 
-	return sales, nil
+	return &sales[0], nil
 }
 
-func (s *Storage) UpdateSale(sale *models.Sale) error {
-	const op = "storage.UpdateSale"
+// SetSaleLocked sets or clears the lock flag on a sale.
+func (s *Storage) SetSaleLocked(id int, locked bool) error {
+	const op = "storage.SetSaleLocked"
 
-	query := `UPDATE sales SET type=$1, amount=$2, date=$3, category=$4 WHERE id=$5`
-	_, err := s.db.Exec(context.Background(), query, sale.Type, sale.Amount, sale.Date, sale.Category, sale.ID)
+	query := `UPDATE sales SET locked=$1 WHERE id=$2 AND deleted_at IS NULL`
+	tag, err := s.db.Exec(context.Background(), query, locked, id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrSaleNotFound)
+	}
 
 	return nil
 }
 
-func (s *Storage) DeleteSale(id int) error {
-	const op = "storage.DeleteSale"
+// RecategorizeSales moves every live, unlocked sale whose category is exactly
+// from to category to, linking it to the matching managed category if any,
+// and returns how many sales changed.
+func (s *Storage) RecategorizeSales(from, to string) (int64, error) {
+	const op = "storage.RecategorizeSales"
+
+	query := `UPDATE sales SET ` + categorySet(2) + `, version=version+1, updated_at=now()
+		WHERE category=$1 AND deleted_at IS NULL AND NOT locked`
+	tag, err := s.db.Exec(context.Background(), query, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// checkLocked explains why a guarded write touched no rows: it returns
+// ErrSaleLocked if the sale exists and is locked, and nil if it doesn't exist.
+func (s *Storage) checkLocked(op string, id int) error {
+	return s.checkWriteConflict(op, id, false, 0)
+}
+
+// checkLockedAny is checkLocked for writes that also apply to soft-deleted
+// sales, which keep their lock.
+func (s *Storage) checkLockedAny(op string, id int) error {
+	var locked bool
+	err := s.db.QueryRow(context.Background(), `SELECT locked FROM sales WHERE id=$1`, id).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if locked {
+		return fmt.Errorf("%s: %w", op, ErrSaleLocked)
+	}
+
+	return nil
+}
 
-	query := `DELETE FROM sales WHERE id=$1`
-	_, err := s.db.Exec(context.Background(), query, id)
+// checkWriteConflict explains why a guarded, versioned write touched no rows:
+// ErrSaleLocked if the sale is locked and force wasn't set, ErrVersionConflict
+// if a non-zero version no longer matches, and nil if the sale doesn't exist.
+func (s *Storage) checkWriteConflict(op string, id int, force bool, version int) error {
+	var locked bool
+	var current int
+	err := s.db.QueryRow(context.Background(), `SELECT locked, version FROM sales WHERE id=$1 AND deleted_at IS NULL`, id).Scan(&locked, &current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	if locked && !force {
+		return fmt.Errorf("%s: %w", op, ErrSaleLocked)
+	}
+	if version != 0 && version != current {
+		return fmt.Errorf("%s: %w", op, ErrVersionConflict)
+	}
 
 	return nil
 }
 
-func (s *Storage) GetAnalytics(from, to time.Time) (*models.AnalyticsResponse, error) {
+// GetAnalytics aggregates sales with dates in [from, to]. Percentiles are
+// fractions in [0, 1]; when none are given, the median and p90 are reported.
+func (s *Storage) GetAnalytics(from, to time.Time, percentiles ...float64) (*models.AnalyticsResponse, error) {
 	const op = "storage.GetAnalytics"
 
+	analytics, err := s.analytics(" WHERE date BETWEEN $1 AND $2 AND deleted_at IS NULL", []any{from, to}, percentiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return analytics, nil
+}
+
+// GetAnalyticsFiltered is GetAnalytics over the sales matching filter, with
+// the same conditions as GetSalesFiltered. Sorting and paging fields are
+// ignored.
+func (s *Storage) GetAnalyticsFiltered(filter models.SaleFilter, percentiles ...float64) (*models.AnalyticsResponse, error) {
+	const op = "storage.GetAnalyticsFiltered"
+
+	filter.After = nil
+	where, args := buildSaleFilter(filter)
+	analytics, err := s.analytics(where, args, percentiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return analytics, nil
+}
+
+// analytics aggregates the sales selected by where, a WHERE clause over
+// args. The percentiles go in the next positional argument.
+func (s *Storage) analytics(where string, args []any, percentiles []float64) (*models.AnalyticsResponse, error) {
+	if len(percentiles) == 0 {
+		percentiles = defaultPercentiles
+	}
+
 	query := `
 		SELECT 
 			COALESCE(SUM(amount), 0) as sum,
 			COALESCE(AVG(amount), 0) as average,
 			COUNT(*) as count,
 			COALESCE(PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY amount), 0) as median,
-			COALESCE(PERCENTILE_CONT(0.9) WITHIN GROUP (ORDER BY amount), 0) as percentile90
-		FROM sales 
-		WHERE date BETWEEN $1 AND $2
-	`
+			COALESCE(PERCENTILE_CONT(0.9) WITHIN GROUP (ORDER BY amount), 0) as percentile90,
+			COALESCE(MIN(amount), 0) as min,
+			COALESCE(MAX(amount), 0) as max,
+			COALESCE(STDDEV_SAMP(amount), 0) as stddev,
+			COALESCE(VAR_SAMP(amount), 0) as variance,
+			COALESCE(SUM(amount) FILTER (WHERE type = 'income'), 0) as income_sum,
+			COALESCE(SUM(amount) FILTER (WHERE type = 'expense'), 0) as expense_sum,
+			COUNT(*) FILTER (WHERE type = 'income') as income_count,
+			COUNT(*) FILTER (WHERE type = 'expense') as expense_count,
+			PERCENTILE_CONT($` + strconv.Itoa(len(args)+1) + `::float8[]) WITHIN GROUP (ORDER BY amount) as percentiles
+		FROM sales` + where
 
 	var analytics models.AnalyticsResponse
-	err := s.db.QueryRow(context.Background(), query, from, to).Scan(
+	var values []float64
+	err := s.db.QueryRow(context.Background(), query, append(args, percentiles)...).Scan(
 		&analytics.Sum,
 		&analytics.Average,
 		&analytics.Count,
 		&analytics.Median,
 		&analytics.Percentile90,
+		&analytics.Min,
+		&analytics.Max,
+		&analytics.StdDev,
+		&analytics.Variance,
+		&analytics.IncomeSum,
+		&analytics.ExpenseSum,
+		&analytics.IncomeCount,
+		&analytics.ExpenseCount,
+		&values,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, err
+	}
+	analytics.Net = models.SignedAmount("income", analytics.IncomeSum).Add(models.SignedAmount("expense", analytics.ExpenseSum))
+	analytics.SavingsRate, analytics.ExpenseRatio = models.IncomeRatios(analytics.IncomeSum, analytics.ExpenseSum)
+
+	// PERCENTILE_CONT yields NULL over an empty range; report zeros instead.
+	analytics.Percentiles = make(map[string]float64, len(percentiles))
+	for i, p := range percentiles {
+		var v float64
+		if i < len(values) {
+			v = values[i]
+		}
+		analytics.Percentiles[PercentileKey(p)] = v
 	}
 
 	return &analytics, nil
 }
+
+// LastModified reports the latest updated_at and the count of sales dated in
+// [from, to]. Any create, edit, delete or restore in the range changes one of
+// the two, so together they identify a version of the range's analytics. The
+// query only touches the date index, unlike the aggregates themselves.
+func (s *Storage) LastModified(from, to time.Time) (time.Time, int64, error) {
+	const op = "storage.LastModified"
+
+	var (
+		modified time.Time
+		count    int64
+	)
+	query := `SELECT COALESCE(MAX(updated_at), 'epoch'), COUNT(*) FROM sales WHERE date BETWEEN $1 AND $2 AND deleted_at IS NULL`
+	if err := s.db.QueryRow(context.Background(), query, from, to).Scan(&modified, &count); err != nil {
+		return time.Time{}, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return modified, count, nil
+}
+
+// GetTimeSeries buckets sales with dates in [from, to] by interval ("day",
+// "week" or "month"), returning non-empty periods in ascending order. Periods
+// follow the calendar of the IANA time zone tz.
+func (s *Storage) GetTimeSeries(from, to time.Time, interval, tz string) ([]models.TimeSeriesPoint, error) {
+	const op = "storage.GetTimeSeries"
+
+	query := `
+		SELECT date_trunc($1, date AT TIME ZONE $4) AT TIME ZONE $4 as period, SUM(amount) as sum, COUNT(*) as count
+		FROM sales
+		WHERE date BETWEEN $2 AND $3 AND deleted_at IS NULL
+		GROUP BY 1
+		ORDER BY 1
+	`
+	rows, err := s.db.Query(context.Background(), query, interval, from, to, tz)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	points := []models.TimeSeriesPoint{}
+	for rows.Next() {
+		var point models.TimeSeriesPoint
+		if err := rows.Scan(&point.Period, &point.Sum, &point.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return points, nil
+}
+
+// GetNetWorth buckets sales with dates in [from, to] by interval like
+// GetTimeSeries, returning for each non-empty period its net (income minus
+// expenses) and the running total of the net. The running total starts from
+// the net of all sales before from, so it is the balance at the end of the
+// period rather than just the change within the range.
+func (s *Storage) GetNetWorth(from, to time.Time, interval, tz string) ([]models.NetWorthPoint, error) {
+	const op = "storage.GetNetWorth"
+
+	query := `
+		SELECT period, net,
+			(SELECT COALESCE(SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END), 0)
+			 FROM sales WHERE date < $2 AND deleted_at IS NULL)
+			+ SUM(net) OVER (ORDER BY period) AS cumulative
+		FROM (
+			SELECT date_trunc($1, date AT TIME ZONE $4) AT TIME ZONE $4 AS period,
+				SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END) AS net
+			FROM sales
+			WHERE date BETWEEN $2 AND $3 AND deleted_at IS NULL
+			GROUP BY 1
+		) periods
+		ORDER BY period
+	`
+	rows, err := s.db.Query(context.Background(), query, interval, from, to, tz)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	points := []models.NetWorthPoint{}
+	for rows.Next() {
+		var point models.NetWorthPoint
+		if err := rows.Scan(&point.Period, &point.Net, &point.Cumulative); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return points, nil
+}
+
+// DistributionDimensions maps each dimension GetDistribution accepts to the
+// date field it extracts and the number of buckets it has.
+var DistributionDimensions = map[string]struct {
+	Field   string
+	Buckets int
+}{
+	"weekday": {"DOW", 7},
+	"hour":    {"HOUR", 24},
+}
+
+// GetDistribution totals sales of saleType with dates in [from, to] by day of
+// the week (0 is Sunday) or hour of the day, as read in the IANA time zone
+// tz. Every bucket is returned, in order, including empty ones.
+func (s *Storage) GetDistribution(from, to time.Time, dimension, saleType, tz string) ([]models.DistributionBucket, error) {
+	const op = "storage.GetDistribution"
+
+	dim, ok := DistributionDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown dimension %q", op, dimension)
+	}
+
+	query := `
+		SELECT EXTRACT(` + dim.Field + ` FROM date AT TIME ZONE $4)::int AS bucket, SUM(amount), COUNT(*)
+		FROM sales
+		WHERE date BETWEEN $1 AND $2 AND type = $3 AND deleted_at IS NULL
+		GROUP BY 1
+	`
+	rows, err := s.db.Query(context.Background(), query, from, to, saleType, tz)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	buckets := make([]models.DistributionBucket, dim.Buckets)
+	for i := range buckets {
+		buckets[i].Bucket = i
+	}
+	for rows.Next() {
+		var b models.DistributionBucket
+		if err := rows.Scan(&b.Bucket, &b.Sum, &b.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if b.Bucket >= 0 && b.Bucket < len(buckets) {
+			buckets[b.Bucket] = b
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buckets, nil
+}
+
+// GetActiveDays lists the distinct calendar days, in the given IANA time
+// zone, that have at least one sale. Days are returned in ascending order as
+// midnight UTC.
+func (s *Storage) GetActiveDays(timezone string) ([]time.Time, error) {
+	const op = "storage.GetActiveDays"
+
+	query := `SELECT DISTINCT (date AT TIME ZONE $1)::date AS day FROM sales WHERE deleted_at IS NULL ORDER BY day`
+	rows, err := s.db.Query(context.Background(), query, timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		days = append(days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return days, nil
+}
+
+// GetPeriods lists the calendar months, in the IANA time zone tz, that have at
+// least one live sale, most recent first.
+func (s *Storage) GetPeriods(tz string) ([]models.Period, error) {
+	const op = "storage.GetPeriods"
+
+	query := `
+		SELECT to_char(date_trunc('month', date AT TIME ZONE $1), 'YYYY-MM') as month, COUNT(*) as count, SUM(amount) as sum
+		FROM sales
+		WHERE deleted_at IS NULL
+		GROUP BY month
+		ORDER BY month DESC`
+	rows, err := s.db.Query(context.Background(), query, tz)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	periods := []models.Period{}
+	for rows.Next() {
+		var period models.Period
+		if err := rows.Scan(&period.Month, &period.Count, &period.Sum); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		periods = append(periods, period)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return periods, nil
+}
+
+var defaultPercentiles = []float64{0.5, 0.9}
+
+// PercentileKey names a fractional percentile in AnalyticsResponse.Percentiles,
+// e.g. 0.95 -> "p95".
+func PercentileKey(p float64) string {
+	return "p" + strconv.FormatFloat(p*100, 'g', 6, 64)
+}
+
+// SumByType totals the amounts of one sale type with dates in [from, to].
+func (s *Storage) SumByType(saleType string, from, to time.Time) (decimal.Decimal, error) {
+	const op = "storage.SumByType"
+
+	query := `SELECT COALESCE(SUM(amount), 0) FROM sales WHERE type = $1 AND date BETWEEN $2 AND $3 AND deleted_at IS NULL`
+
+	var sum decimal.Decimal
+	if err := s.db.QueryRow(context.Background(), query, saleType, from, to).Scan(&sum); err != nil {
+		return decimal.Zero, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sum, nil
+}
+
+// GetTopCategories returns the limit categories with the largest totals of
+// saleType sales dated in [from, to], largest first.
+func (s *Storage) GetTopCategories(from, to time.Time, saleType string, limit int) ([]models.CategoryTotal, error) {
+	const op = "storage.GetTopCategories"
+
+	query := `
+		SELECT category, SUM(amount) as total
+		FROM sales
+		WHERE type = $1 AND date BETWEEN $2 AND $3 AND deleted_at IS NULL
+		GROUP BY category
+		ORDER BY total DESC, category
+		LIMIT $4`
+	rows, err := s.db.Query(context.Background(), query, saleType, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	totals := []models.CategoryTotal{}
+	for rows.Next() {
+		var total models.CategoryTotal
+		if err := rows.Scan(&total.Category, &total.Total); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		totals = append(totals, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totals, nil
+}
+
+// GetTagTotals totals the sales of saleType dated in [from, to] per tag,
+// largest first. A sale with several tags counts towards each of them.
+func (s *Storage) GetTagTotals(from, to time.Time, saleType string) ([]models.TagTotal, error) {
+	const op = "storage.GetTagTotals"
+
+	query := `
+		SELECT tag, SUM(amount) as total, COUNT(*) as count
+		FROM sales, unnest(tags) AS tag
+		WHERE date BETWEEN $1 AND $2 AND deleted_at IS NULL AND type = $3
+		GROUP BY tag
+		ORDER BY total DESC, tag`
+	rows, err := s.db.Query(context.Background(), query, from, to, saleType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	totals := []models.TagTotal{}
+	for rows.Next() {
+		var total models.TagTotal
+		if err := rows.Scan(&total.Tag, &total.Total, &total.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		totals = append(totals, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totals, nil
+}
+
+// GetPaymentMethodTotals totals the sales of saleType dated in [from, to] per
+// payment method, largest first. Sales without one are totalled under an
+// empty method.
+func (s *Storage) GetPaymentMethodTotals(from, to time.Time, saleType string) ([]models.PaymentMethodTotal, error) {
+	const op = "storage.GetPaymentMethodTotals"
+
+	query := `
+		SELECT COALESCE(payment_method, '') AS method, SUM(amount) AS total, COUNT(*) AS count
+		FROM sales
+		WHERE type = $1 AND date BETWEEN $2 AND $3 AND deleted_at IS NULL
+		GROUP BY 1
+		ORDER BY total DESC, method`
+	rows, err := s.db.Query(context.Background(), query, saleType, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	totals := []models.PaymentMethodTotal{}
+	for rows.Next() {
+		var total models.PaymentMethodTotal
+		if err := rows.Scan(&total.Method, &total.Total, &total.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		totals = append(totals, total)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totals, nil
+}
+
+// GetDailyExpenses returns, for each calendar day in the IANA time zone tz
+// with at least one live sale dated in [from, to], the total of that day's
+// expenses (zero on income-only days), in ascending order.
+func (s *Storage) GetDailyExpenses(from, to time.Time, tz string) ([]models.DailyTotal, error) {
+	const op = "storage.GetDailyExpenses"
+
+	query := `
+		SELECT to_char(date_trunc('day', date AT TIME ZONE $3), 'YYYY-MM-DD') as day,
+			COALESCE(SUM(amount) FILTER (WHERE type = 'expense'), 0) as expense
+		FROM sales
+		WHERE date BETWEEN $1 AND $2 AND deleted_at IS NULL
+		GROUP BY day
+		ORDER BY day`
+	rows, err := s.db.Query(context.Background(), query, from, to, tz)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	days := []models.DailyTotal{}
+	for rows.Next() {
+		var day models.DailyTotal
+		if err := rows.Scan(&day.Date, &day.Expense); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		days = append(days, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return days, nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"L3_6/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+func (s *Storage) CreateWebhookDelivery(d *models.WebhookDelivery) error {
+	const op = "storage.CreateWebhookDelivery"
+
+	query := `
+		INSERT INTO webhook_deliveries (url, event, payload, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, attempts, created_at, updated_at
+	`
+	err := s.db.QueryRow(context.Background(), query, d.URL, d.Event, d.Payload, d.Status).
+		Scan(&d.ID, &d.Attempts, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateWebhookDelivery records the outcome of delivery attempts.
+func (s *Storage) UpdateWebhookDelivery(d *models.WebhookDelivery) error {
+	const op = "storage.UpdateWebhookDelivery"
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status=$1, attempts=$2, last_error=$3, updated_at=now()
+		WHERE id=$4
+		RETURNING updated_at
+	`
+	err := s.db.QueryRow(context.Background(), query, d.Status, d.Attempts, d.LastError, d.ID).Scan(&d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetWebhookDelivery(id int) (*models.WebhookDelivery, error) {
+	const op = "storage.GetWebhookDelivery"
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id=$1`
+	rows, err := s.db.Query(context.Background(), query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrDeliveryNotFound)
+	}
+
+	return &deliveries[0], nil
+}
+
+// GetWebhookDeliveries lists deliveries newest first, optionally restricted
+// to one status.
+func (s *Storage) GetWebhookDeliveries(status string) ([]models.WebhookDelivery, error) {
+	const op = "storage.GetWebhookDeliveries"
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE ($1 = '' OR status = $1) ORDER BY id DESC`
+	rows, err := s.db.Query(context.Background(), query, status)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+const deliveryColumns = `id, url, event, payload, status, attempts, last_error, created_at, updated_at`
+
+func scanDeliveries(rows pgx.Rows) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(&d.ID, &d.URL, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
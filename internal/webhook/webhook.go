@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"L3_6/models"
+)
+
+// ErrDeadLettered is returned by Deliver when every attempt failed.
+var ErrDeadLettered = errors.New("webhook delivery dead-lettered")
+
+// ErrDeliveryInProgress is returned by Redeliver when the delivery is already
+// being attempted.
+var ErrDeliveryInProgress = errors.New("webhook delivery in progress")
+
+// Store persists delivery records so failed deliveries stay observable.
+type Store interface {
+	CreateWebhookDelivery(d *models.WebhookDelivery) error
+	UpdateWebhookDelivery(d *models.WebhookDelivery) error
+	GetWebhookDelivery(id int) (*models.WebhookDelivery, error)
+}
+
+// Dispatcher POSTs sale events to the configured URLs. Each delivery is
+// retried with exponential backoff and dead-lettered once it runs out of
+// attempts; dead deliveries can be retried later with Redeliver.
+type Dispatcher struct {
+	store       Store
+	client      *http.Client
+	urls        []string
+	maxAttempts int
+	retryDelay  time.Duration
+
+	// inFlight holds the IDs of deliveries being attempted in the
+	// background, so one delivery is never sent by two rounds at once.
+	mu       sync.Mutex
+	inFlight map[int]bool
+}
+
+func NewDispatcher(store Store, cfg *models.Config) *Dispatcher {
+	maxAttempts := cfg.Webhooks.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: cfg.Webhooks.Timeout},
+		urls:        cfg.Webhooks.URLs,
+		maxAttempts: maxAttempts,
+		retryDelay:  cfg.Webhooks.RetryDelay,
+		inFlight:    make(map[int]bool),
+	}
+}
+
+// Notify records a delivery per configured URL and sends them in the
+// background. It is a no-op when no URLs are configured.
+func (d *Dispatcher) Notify(event string, data any) {
+	if len(d.urls) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]any{"event": event, "data": data})
+	if err != nil {
+		slog.Error("webhook: marshal payload", "event", event, "err", err)
+		return
+	}
+
+	for _, url := range d.urls {
+		delivery := &models.WebhookDelivery{
+			URL:     url,
+			Event:   event,
+			Payload: payload,
+			Status:  models.DeliveryPending,
+		}
+		if err := d.store.CreateWebhookDelivery(delivery); err != nil {
+			slog.Error("webhook: record delivery", "event", event, "url", url, "err", err)
+			continue
+		}
+		d.claim(delivery.ID)
+		go d.deliverInBackground(delivery)
+	}
+}
+
+// deliverInBackground runs Deliver for a delivery claimed by the caller and
+// releases it when done.
+func (d *Dispatcher) deliverInBackground(delivery *models.WebhookDelivery) {
+	defer d.release(delivery.ID)
+	if err := d.Deliver(delivery); err != nil {
+		slog.Warn("webhook: delivery failed", "id", delivery.ID, "url", delivery.URL, "err", err)
+	}
+}
+
+// claim marks the delivery as being attempted, reporting false if it
+// already was.
+func (d *Dispatcher) claim(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.inFlight[id] {
+		return false
+	}
+	d.inFlight[id] = true
+	return true
+}
+
+func (d *Dispatcher) release(id int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, id)
+}
+
+// Deliver attempts the delivery up to the configured number of times,
+// persisting progress after each attempt. It returns an ErrDeadLettered error
+// wrapping the last failure if every attempt failed.
+func (d *Dispatcher) Deliver(delivery *models.WebhookDelivery) error {
+	delay := d.retryDelay
+
+	var lastErr error
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		lastErr = d.send(delivery)
+		delivery.Attempts++
+		if lastErr == nil {
+			delivery.Status = models.DeliveryDelivered
+			delivery.LastError = ""
+			return d.store.UpdateWebhookDelivery(delivery)
+		}
+
+		delivery.LastError = lastErr.Error()
+		if attempt == d.maxAttempts {
+			delivery.Status = models.DeliveryDead
+		}
+		if err := d.store.UpdateWebhookDelivery(delivery); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %v", ErrDeadLettered, lastErr)
+}
+
+// Redeliver starts a fresh round of attempts for a stored delivery,
+// typically a dead-lettered one, in the background. It returns the delivery
+// marked pending; the outcome shows up in the stored record. It returns
+// ErrDeliveryInProgress if the delivery is already being attempted.
+func (d *Dispatcher) Redeliver(id int) (*models.WebhookDelivery, error) {
+	if !d.claim(id) {
+		return nil, ErrDeliveryInProgress
+	}
+
+	delivery, err := d.store.GetWebhookDelivery(id)
+	if err != nil {
+		d.release(id)
+		return nil, err
+	}
+
+	delivery.Status = models.DeliveryPending
+	if err := d.store.UpdateWebhookDelivery(delivery); err != nil {
+		d.release(id)
+		return nil, err
+	}
+
+	queued := *delivery
+	go d.deliverInBackground(delivery)
+	return &queued, nil
+}
+
+func (d *Dispatcher) send(delivery *models.WebhookDelivery) error {
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", fmt.Sprint(delivery.ID))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"L3_6/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory Store for exercising delivery bookkeeping.
+type memoryStore struct {
+	mu         sync.Mutex
+	deliveries map[int]models.WebhookDelivery
+	nextID     int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{deliveries: map[int]models.WebhookDelivery{}}
+}
+
+func (m *memoryStore) CreateWebhookDelivery(d *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	d.ID = m.nextID
+	m.deliveries[d.ID] = *d
+	return nil
+}
+
+func (m *memoryStore) UpdateWebhookDelivery(d *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[d.ID] = *d
+	return nil
+}
+
+func (m *memoryStore) GetWebhookDelivery(id int) (*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deliveries[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &d, nil
+}
+
+func TestDispatcher_DeadLetterAndRedeliver(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer endpoint.Close()
+
+	cfg := &models.Config{}
+	cfg.Webhooks.URLs = []string{endpoint.URL}
+	cfg.Webhooks.MaxAttempts = 3
+	cfg.Webhooks.RetryDelay = time.Millisecond
+	cfg.Webhooks.Timeout = time.Second
+
+	store := newMemoryStore()
+	dispatcher := NewDispatcher(store, cfg)
+
+	delivery := &models.WebhookDelivery{
+		URL:     endpoint.URL,
+		Event:   "sale.created",
+		Payload: []byte(`{"event":"sale.created","data":{"id":1}}`),
+		Status:  models.DeliveryPending,
+	}
+	require.NoError(t, store.CreateWebhookDelivery(delivery))
+
+	t.Run("failing endpoint dead-letters the delivery", func(t *testing.T) {
+		err := dispatcher.Deliver(delivery)
+		assert.ErrorIs(t, err, ErrDeadLettered)
+
+		stored, err := store.GetWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryDead, stored.Status)
+		assert.Equal(t, 3, stored.Attempts)
+		assert.Contains(t, stored.LastError, "500")
+		assert.Equal(t, int32(3), hits.Load())
+	})
+
+	t.Run("dead delivery can be redelivered", func(t *testing.T) {
+		healthy.Store(true)
+
+		redelivered, err := dispatcher.Redeliver(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryPending, redelivered.Status, "redelivery happens in the background")
+
+		require.Eventually(t, func() bool {
+			stored, err := store.GetWebhookDelivery(delivery.ID)
+			return err == nil && stored.Status == models.DeliveryDelivered
+		}, 5*time.Second, 5*time.Millisecond)
+		stored, err := store.GetWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 4, stored.Attempts)
+		assert.Empty(t, stored.LastError)
+	})
+
+	t.Run("one redelivery at a time", func(t *testing.T) {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer slow.Close()
+		pending := &models.WebhookDelivery{URL: slow.URL, Event: "sale.created", Status: models.DeliveryDead}
+		require.NoError(t, store.CreateWebhookDelivery(pending))
+
+		_, err := dispatcher.Redeliver(pending.ID)
+		require.NoError(t, err)
+		_, err = dispatcher.Redeliver(pending.ID)
+		assert.ErrorIs(t, err, ErrDeliveryInProgress)
+
+		close(release)
+		require.Eventually(t, func() bool {
+			_, err := dispatcher.Redeliver(pending.ID)
+			return err == nil
+		}, 5*time.Second, 5*time.Millisecond, "the lock is released once the round finishes")
+	})
+}
+
+func TestDispatcher_NotifyWithoutURLs(t *testing.T) {
+	store := newMemoryStore()
+	NewDispatcher(store, &models.Config{}).Notify("sale.created", map[string]int{"id": 1})
+	assert.Empty(t, store.deliveries)
+}
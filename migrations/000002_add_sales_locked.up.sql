@@ -0,0 +1 @@
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE;
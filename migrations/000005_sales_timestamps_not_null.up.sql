@@ -0,0 +1,5 @@
+UPDATE sales SET created_at = COALESCE(created_at, CURRENT_TIMESTAMP), updated_at = COALESCE(updated_at, created_at, CURRENT_TIMESTAMP)
+WHERE created_at IS NULL OR updated_at IS NULL;
+
+ALTER TABLE sales ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE sales ALTER COLUMN updated_at SET NOT NULL;
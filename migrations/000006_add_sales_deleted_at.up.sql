@@ -0,0 +1,3 @@
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_sales_live_date ON sales(date) WHERE deleted_at IS NULL;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL CHECK (TRIM(name) <> ''),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name_lower ON categories (LOWER(name));
+
+-- Seed from the names already in use, collapsing case variants.
+INSERT INTO categories (name)
+SELECT DISTINCT ON (LOWER(TRIM(category))) TRIM(category)
+FROM sales
+WHERE TRIM(category) <> ''
+ORDER BY LOWER(TRIM(category)), TRIM(category)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+
+UPDATE sales s SET category_id = c.id
+FROM categories c
+WHERE s.category_id IS NULL AND LOWER(c.name) = LOWER(TRIM(s.category));
+
+CREATE INDEX IF NOT EXISTS idx_sales_category_id ON sales(category_id);
@@ -0,0 +1 @@
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS budgets (
+    id SERIAL PRIMARY KEY,
+    category VARCHAR(255) NOT NULL CHECK (TRIM(category) <> ''),
+    month DATE NOT NULL CHECK (EXTRACT(DAY FROM month) = 1),
+    "limit" DECIMAL(10,2) NOT NULL CHECK ("limit" > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One budget per category and month, matching sale categories ignoring case.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_month_category ON budgets (month, LOWER(category));
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS recurring_rules (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(10) NOT NULL CHECK (type IN ('income', 'expense')),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    category VARCHAR(255) NOT NULL,
+    interval VARCHAR(10) NOT NULL CHECK (interval IN ('daily', 'weekly', 'monthly', 'yearly')),
+    next_date TIMESTAMPTZ NOT NULL,
+    -- Occurrences are computed as anchor_date plus occurrences intervals so
+    -- month-end dates don't drift after a short month.
+    anchor_date TIMESTAMPTZ NOT NULL,
+    occurrences INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_recurring_rules_next_date ON recurring_rules(next_date);
+
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS recurring_rule_id INTEGER REFERENCES recurring_rules(id) ON DELETE SET NULL;
+
+-- A rule materializes at most one sale per occurrence, even across restarts.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_recurring_occurrence ON sales(recurring_rule_id, date) WHERE recurring_rule_id IS NOT NULL;
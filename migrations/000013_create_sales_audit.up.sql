@@ -0,0 +1,51 @@
+-- sales_audit keeps the previous values of a sale each time it changes, so
+-- its history survives edits and deletes. A trigger fills it in, which
+-- covers every write path, including bulk updates and hard deletes.
+CREATE TABLE IF NOT EXISTS sales_audit (
+    id BIGSERIAL PRIMARY KEY,
+    sale_id INTEGER NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('update', 'delete', 'restore')),
+    type VARCHAR(10) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    date TIMESTAMPTZ NOT NULL,
+    category VARCHAR(255) NOT NULL,
+    locked BOOLEAN NOT NULL,
+    lat DOUBLE PRECISION,
+    lng DOUBLE PRECISION,
+    version INTEGER NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sales_audit_sale_id ON sales_audit(sale_id, changed_at);
+
+CREATE OR REPLACE FUNCTION audit_sale_change() RETURNS trigger AS $$
+DECLARE
+    change VARCHAR(10);
+BEGIN
+    IF TG_OP = 'DELETE' OR (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL) THEN
+        change := 'delete';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        change := 'restore';
+    ELSE
+        change := 'update';
+    END IF;
+
+    INSERT INTO sales_audit (sale_id, action, type, amount, date, category, locked, lat, lng, version)
+    VALUES (OLD.id, change, OLD.type, OLD.amount, OLD.date, OLD.category, OLD.locked, OLD.lat, OLD.lng, OLD.version);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS sales_audit_update ON sales;
+CREATE TRIGGER sales_audit_update
+    AFTER UPDATE ON sales
+    FOR EACH ROW
+    WHEN (OLD.* IS DISTINCT FROM NEW.*)
+    EXECUTE FUNCTION audit_sale_change();
+
+DROP TRIGGER IF EXISTS sales_audit_delete ON sales;
+CREATE TRIGGER sales_audit_delete
+    AFTER DELETE ON sales
+    FOR EACH ROW
+    EXECUTE FUNCTION audit_sale_change();
@@ -0,0 +1,26 @@
+-- tags are free-form labels beyond the single category. The GIN index
+-- serves containment filters such as tags @> '{travel}'.
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_sales_tags ON sales USING gin (tags);
+
+ALTER TABLE sales_audit ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE OR REPLACE FUNCTION audit_sale_change() RETURNS trigger AS $$
+DECLARE
+    change VARCHAR(10);
+BEGIN
+    IF TG_OP = 'DELETE' OR (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL) THEN
+        change := 'delete';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        change := 'restore';
+    ELSE
+        change := 'update';
+    END IF;
+
+    INSERT INTO sales_audit (sale_id, action, type, amount, date, category, tags, locked, lat, lng, version)
+    VALUES (OLD.id, change, OLD.type, OLD.amount, OLD.date, OLD.category, OLD.tags, OLD.locked, OLD.lat, OLD.lng, OLD.version);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,13 @@
+-- attachments holds files such as receipt scans uploaded for a sale. They
+-- go when the sale is hard-deleted; a soft delete keeps them for a restore.
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    sale_id INTEGER NOT NULL REFERENCES sales(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL CHECK (TRIM(filename) <> ''),
+    content_type VARCHAR(100) NOT NULL,
+    size INTEGER NOT NULL CHECK (size > 0),
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_sale_id ON attachments(sale_id);
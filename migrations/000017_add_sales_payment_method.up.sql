@@ -0,0 +1,24 @@
+-- payment_method is optional free text such as "card" or "cash"; NULL when
+-- absent.
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS payment_method TEXT;
+
+ALTER TABLE sales_audit ADD COLUMN IF NOT EXISTS payment_method TEXT;
+
+CREATE OR REPLACE FUNCTION audit_sale_change() RETURNS trigger AS $$
+DECLARE
+    change VARCHAR(10);
+BEGIN
+    IF TG_OP = 'DELETE' OR (OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL) THEN
+        change := 'delete';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        change := 'restore';
+    ELSE
+        change := 'update';
+    END IF;
+
+    INSERT INTO sales_audit (sale_id, action, type, amount, date, category, tags, note, payment_method, locked, lat, lng, version)
+    VALUES (OLD.id, change, OLD.type, OLD.amount, OLD.date, OLD.category, OLD.tags, OLD.note, OLD.payment_method, OLD.locked, OLD.lat, OLD.lng, OLD.version);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -1,32 +1,656 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/shopspring/decimal"
+)
+
+// Sale is a single income or expense transaction. Amount is an exact decimal
+// that marshals to JSON as a numeric string (e.g. "1000.5") so no precision is
+// lost in transit; both strings and numbers are accepted on input.
+// CategoryID links the sale to the managed category matching Category, if
+// any. It and CreatedAt/UpdatedAt are managed by the server; values sent by
+// clients are ignored. Version counts edits; clients send back the version
+// they read so concurrent updates are detected (zero skips the check).
+// PaymentMethod is optional free text such as "card" or "cash".
+// RunningBalance is only set on listings that ask for it.
 type Sale struct {
-	ID       int       `json:"id"`
-	Type     string    `json:"type" validate:"required,oneof=income expense"`
-	Amount   float64   `json:"amount" validate:"required,gt=0"`
-	Date     time.Time `json:"date" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
-	Category string    `json:"category" validate:"required"`
+	ID            int             `json:"id"`
+	Type          string          `json:"type" validate:"required,oneof=income expense"`
+	Amount        decimal.Decimal `json:"amount" validate:"required,gt=0"`
+	Date          time.Time       `json:"date" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	Category      string          `json:"category" validate:"required"`
+	CategoryID    *int            `json:"category_id,omitempty"`
+	Tags          []string        `json:"tags"`
+	Note          string          `json:"note"`
+	PaymentMethod string          `json:"payment_method"`
+	Locked        bool            `json:"locked"`
+	Lat           *float64        `json:"lat,omitempty"`
+	Lng           *float64        `json:"lng,omitempty"`
+	Version       int             `json:"version"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	RunningBalance *decimal.Decimal `json:"running_balance,omitempty"`
+}
+
+// SignedAmount returns the sale's effect on the balance: negative for an expense.
+func (s Sale) SignedAmount() decimal.Decimal {
+	return SignedAmount(s.Type, s.Amount)
+}
+
+// SignedAmount applies the amount sign convention. Amounts are stored and
+// exchanged as positive values (the database enforces amount > 0) and the
+// type carries the direction, so expenses count negatively toward balances
+// and net totals.
+func SignedAmount(saleType string, amount decimal.Decimal) decimal.Decimal {
+	if saleType == "expense" {
+		return amount.Neg()
+	}
+	return amount
 }
 
+// IncomeRatios returns the savings rate, (income - expense) / income, and the
+// expense ratio, expense / income, as percentages rounded to two decimal
+// places. Both are nil when there is no income, since neither is defined.
+func IncomeRatios(income, expense decimal.Decimal) (savingsRate, expenseRatio *decimal.Decimal) {
+	if !income.IsPositive() {
+		return nil, nil
+	}
+	hundred := decimal.NewFromInt(100)
+	savings := income.Sub(expense).Mul(hundred).DivRound(income, 2)
+	ratio := expense.Mul(hundred).DivRound(income, 2)
+	return &savings, &ratio
+}
+
+// Category is a managed category. Names are unique ignoring case.
+type Category struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	SaleCount int       `json:"sale_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CategoryUsage is a category name as written on sales, with the number of
+// sales using it.
+type CategoryUsage struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Budget caps the expenses in Category (matched ignoring case) for Month,
+// formatted as YYYY-MM.
+type Budget struct {
+	ID        int             `json:"id"`
+	Category  string          `json:"category"`
+	Month     string          `json:"month"`
+	Limit     decimal.Decimal `json:"limit"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// BudgetStatus compares a budget with the expenses actually recorded for its
+// category and month. Remaining is negative when Over is set.
+type BudgetStatus struct {
+	BudgetID  int             `json:"budget_id"`
+	Category  string          `json:"category"`
+	Month     string          `json:"month"`
+	Limit     decimal.Decimal `json:"limit"`
+	Spent     decimal.Decimal `json:"spent"`
+	Remaining decimal.Decimal `json:"remaining"`
+	Over      bool            `json:"over"`
+}
+
+// Attachment is a file, such as a receipt, uploaded for a sale. Data holds
+// the contents and is only loaded for downloads; it never appears in JSON.
+type Attachment struct {
+	ID          int       `json:"id"`
+	SaleID      int       `json:"sale_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	Data        []byte    `json:"-"`
+}
+
+// RecurringRule describes a sale that repeats every Interval (daily, weekly,
+// monthly or yearly). NextDate is the date of the next sale to be created;
+// the server advances it as sales are materialized.
+type RecurringRule struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	Amount    decimal.Decimal `json:"amount"`
+	Category  string          `json:"category"`
+	Interval  string          `json:"interval"`
+	NextDate  time.Time       `json:"next_date"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// AuditEntry records a sale's values just before a change. Action is
+// "update", "delete" or "restore"; the sale's current values are not an
+// entry, so its history lists every earlier version.
+type AuditEntry struct {
+	ID            int64           `json:"id"`
+	SaleID        int             `json:"sale_id"`
+	Action        string          `json:"action"`
+	Type          string          `json:"type"`
+	Amount        decimal.Decimal `json:"amount"`
+	Date          time.Time       `json:"date"`
+	Category      string          `json:"category"`
+	Tags          []string        `json:"tags"`
+	Note          string          `json:"note"`
+	PaymentMethod string          `json:"payment_method"`
+	Locked        bool            `json:"locked"`
+	Lat           *float64        `json:"lat,omitempty"`
+	Lng           *float64        `json:"lng,omitempty"`
+	Version       int             `json:"version"`
+	ChangedAt     time.Time       `json:"changed_at"`
+}
+
+// SalePatch is the body of a partial update; nil fields are left unchanged.
+type SalePatch struct {
+	Type          *string          `json:"type"`
+	Amount        *decimal.Decimal `json:"amount"`
+	Date          *time.Time       `json:"date"`
+	Category      *string          `json:"category"`
+	Tags          *[]string        `json:"tags"`
+	Note          *string          `json:"note"`
+	PaymentMethod *string          `json:"payment_method"`
+	Lat           *float64         `json:"lat"`
+	Lng           *float64         `json:"lng"`
+	// Version, if set, must match the stored version.
+	Version *int `json:"version"`
+}
+
+// SaleList is the v2 (enveloped) representation of a sales listing.
+type SaleList struct {
+	Items []Sale `json:"items"`
+	Count int    `json:"count"`
+	// NextCursor resumes a paginated listing; empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ImportResult summarizes a CSV import. Rows are imported all-or-nothing, so
+// Imported is zero whenever Errors is non-empty.
+type ImportResult struct {
+	Imported int           `json:"imported"`
+	Errors   []ImportError `json:"errors"`
+}
+
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// SaleFilter narrows a sales listing. Nil fields don't filter.
+type SaleFilter struct {
+	From *time.Time
+	To   *time.Time
+	Near *GeoRadius
+	// Uncategorized holds the default category name; when set, only sales
+	// whose category is blank or equal to it (case-insensitively) match.
+	Uncategorized *string
+	// Type keeps only income or only expenses; Category keeps only sales in
+	// that category, ignoring case. Empty means any.
+	Type     string
+	Category string
+	// PaymentMethod keeps only sales paid that way, ignoring case.
+	PaymentMethod string
+	// Tags keeps only sales carrying every one of the given tags.
+	Tags []string
+	// Sort names the column to order by (see storage.SortableColumns);
+	// empty means date. Results are descending unless Ascending is set.
+	Sort      string
+	Ascending bool
+	// Balance fills in each sale's RunningBalance.
+	Balance bool
+	// After, if set, resumes a date-sorted listing just past the given sale
+	// (keyset pagination). Limit caps the number of sales; zero means all.
+	After *SaleCursor
+	Limit int
+}
+
+// SaleCursor identifies a position in a listing sorted by date, with id
+// breaking ties.
+type SaleCursor struct {
+	Date time.Time
+	ID   int
+}
+
+// GeoRadius selects points within RadiusKm kilometres of (Lat, Lng).
+type GeoRadius struct {
+	Lat      float64
+	Lng      float64
+	RadiusKm float64
+}
+
+// AnalyticsResponse holds aggregate statistics. Money totals and bounds are
+// exact decimals; distribution statistics are floating point.
 type AnalyticsResponse struct {
-	Sum          float64 `json:"sum"`
-	Average      float64 `json:"average"`
-	Count        int     `json:"count"`
-	Median       float64 `json:"median"`
-	Percentile90 float64 `json:"percentile90"`
+	Sum          decimal.Decimal `json:"sum"`
+	Average      decimal.Decimal `json:"average"`
+	Count        int             `json:"count"`
+	Median       float64         `json:"median"`
+	Percentile90 float64         `json:"percentile90"`
+	Min          decimal.Decimal `json:"min"`
+	Max          decimal.Decimal `json:"max"`
+	StdDev       float64         `json:"stddev"`
+	Variance     float64         `json:"variance"`
+	IncomeSum    decimal.Decimal `json:"income_sum"`
+	ExpenseSum   decimal.Decimal `json:"expense_sum"`
+	Net          decimal.Decimal `json:"net"`
+	IncomeCount  int             `json:"income_count"`
+	ExpenseCount int             `json:"expense_count"`
+	// SavingsRate is (income - expense) / income and ExpenseRatio is
+	// expense / income, both as percentages; see IncomeRatios.
+	SavingsRate  *decimal.Decimal `json:"savings_rate"`
+	ExpenseRatio *decimal.Decimal `json:"expense_ratio"`
+	// Percentiles maps "p95"-style keys to values for the requested
+	// percentiles (p50 and p90 when none were requested).
+	Percentiles map[string]float64 `json:"percentiles"`
+	// SampleSize is the number of transactions the statistics are based on;
+	// Reliable is false when it is below the configured minimum.
+	SampleSize int  `json:"sample_size"`
+	Reliable   bool `json:"reliable"`
+	// AvgPerDay is Count divided by the number of calendar days the range
+	// spans, counting both ends.
+	AvgPerDay float64 `json:"avg_per_day"`
 }
 
+type TimeSeriesPoint struct {
+	Period time.Time       `json:"period"`
+	Sum    decimal.Decimal `json:"sum"`
+	Count  int             `json:"count"`
+}
+
+// Period is a calendar month (YYYY-MM) with at least one sale, with the
+// number of its sales and the sum of their amounts.
+type Period struct {
+	Month string          `json:"month"`
+	Count int             `json:"count"`
+	Sum   decimal.Decimal `json:"sum"`
+}
+
+// CategoryTotal is the summed amount of one category's sales.
+type CategoryTotal struct {
+	Category string          `json:"category"`
+	Total    decimal.Decimal `json:"total"`
+}
+
+// DistributionBucket is the total and number of sales falling in one bucket
+// of a distribution: a day of the week (0 is Sunday) or an hour of the day.
+type DistributionBucket struct {
+	Bucket int             `json:"bucket"`
+	Sum    decimal.Decimal `json:"sum"`
+	Count  int             `json:"count"`
+}
+
+// NetWorthPoint is one period of the net worth series: Net is income minus
+// expenses dated in the period, Cumulative the net of every sale up to and
+// including it.
+type NetWorthPoint struct {
+	Period     time.Time       `json:"period"`
+	Net        decimal.Decimal `json:"net"`
+	Cumulative decimal.Decimal `json:"cumulative"`
+}
+
+// TagTotal is the total and number of sales carrying a tag.
+type TagTotal struct {
+	Tag   string          `json:"tag"`
+	Total decimal.Decimal `json:"total"`
+	Count int             `json:"count"`
+}
+
+// PaymentMethodTotal is the total and number of sales paid with one payment
+// method; Method is empty for sales that don't record one.
+type PaymentMethodTotal struct {
+	Method string          `json:"method"`
+	Total  decimal.Decimal `json:"total"`
+	Count  int             `json:"count"`
+}
+
+// DailyTotal is one calendar day's expense total; Date is YYYY-MM-DD.
+type DailyTotal struct {
+	Date    string          `json:"date"`
+	Expense decimal.Decimal `json:"expense"`
+}
+
+// StreakResponse reports runs of consecutive calendar days with at least one
+// transaction. Current is the run ending today, or yesterday if there has been
+// no activity yet today.
+type StreakResponse struct {
+	Longest int `json:"longest"`
+	Current int `json:"current"`
+}
+
+// IntegrityReport counts rows affected by each diagnostic check. Healthy is
+// true when every count is zero.
+type IntegrityReport struct {
+	Issues    map[string]int `json:"issues"`
+	Healthy   bool           `json:"healthy"`
+	CheckedAt time.Time      `json:"checked_at"`
+}
+
+// PoolStats is a snapshot of the database connection pool. EmptyAcquireCount
+// counts acquires that had to wait for a connection, and AcquireDurationMs is
+// the total time spent acquiring.
+type PoolStats struct {
+	AcquiredConns        int32 `json:"acquired_conns"`
+	IdleConns            int32 `json:"idle_conns"`
+	TotalConns           int32 `json:"total_conns"`
+	MaxConns             int32 `json:"max_conns"`
+	AcquireCount         int64 `json:"acquire_count"`
+	EmptyAcquireCount    int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount int64 `json:"canceled_acquire_count"`
+	AcquireDurationMs    int64 `json:"acquire_duration_ms"`
+}
+
+// VersionInfo identifies the running build and the schema version recorded
+// in the database. Dirty means the last migration failed part-way and the
+// schema needs repairing before migrations can run again.
+type VersionInfo struct {
+	Version       string `json:"version"`
+	SchemaVersion uint   `json:"schema_version"`
+	Dirty         bool   `json:"dirty"`
+}
+
+// DashboardResponse bundles what a dashboard home screen shows for a range:
+// the overall analytics, the income/expense split, the largest expense
+// categories and the most recent sales.
+type DashboardResponse struct {
+	From          time.Time         `json:"from"`
+	To            time.Time         `json:"to"`
+	Analytics     AnalyticsResponse `json:"analytics"`
+	Income        TypeTotal         `json:"income"`
+	Expense       TypeTotal         `json:"expense"`
+	TopCategories []CategoryTotal   `json:"top_categories"`
+	Recent        []Sale            `json:"recent"`
+}
+
+// TypeTotal sums the sales of one type.
+type TypeTotal struct {
+	Total decimal.Decimal `json:"total"`
+	Count int             `json:"count"`
+}
+
+type PaceResponse struct {
+	ToDate      decimal.Decimal `json:"to_date"`
+	DaysElapsed int             `json:"days_elapsed"`
+	DaysInMonth int             `json:"days_in_month"`
+	Projected   decimal.Decimal `json:"projected"`
+}
+
+// ForecastResponse projects a month's expenses with a naive linear estimate:
+// the month-to-date total scaled by days in month over days elapsed. Method
+// names the estimate so clients can label it. Budget is the sum of the month's
+// budgets and Remaining is Budget minus the month's expenses in the budgeted
+// categories; both are omitted when the month has no budgets.
+type ForecastResponse struct {
+	Month       string           `json:"month"`
+	Method      string           `json:"method"`
+	ToDate      decimal.Decimal  `json:"to_date"`
+	DaysElapsed int              `json:"days_elapsed"`
+	DaysInMonth int              `json:"days_in_month"`
+	Projected   decimal.Decimal  `json:"projected"`
+	Budget      *decimal.Decimal `json:"budget,omitempty"`
+	Remaining   *decimal.Decimal `json:"remaining,omitempty"`
+}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type WebhookDelivery struct {
+	ID        int             `json:"id"`
+	URL       string          `json:"url"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload" swaggertype:"object"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Config is loaded from config.yaml, or the YAML, JSON or TOML file named by
+// -config or CONFIG_PATH; yaml tags name the keys in YAML and JSON, toml tags
+// in TOML. Fields with an env tag can be overridden by that environment
+// variable, which takes precedence over the file; values missing from both
+// fall back to env-default.
 type Config struct {
 	Server struct {
-		Port string `yaml:"port"`
-	} `yaml:"server"`
+		Port     string `yaml:"port" toml:"port" env:"SERVER_PORT"`
+		AdminKey string `yaml:"admin_key" toml:"admin_key" env:"ADMIN_KEY"`
+		// BasePath prefixes every route, e.g. "/finance" to serve the API at
+		// /finance/api behind a reverse proxy. Empty mounts at the root.
+		BasePath string `yaml:"base_path" toml:"base_path" env:"SERVER_BASE_PATH"`
+		// GinMode is the Gin mode: release, debug or test. Debug logs every
+		// route and warns about insecure defaults, so keep it for
+		// development. Empty leaves the mode Gin picked up at startup.
+		GinMode string `yaml:"gin_mode" toml:"gin_mode" env:"GIN_MODE" env-default:"release"`
+		// StrictSaleTypes rejects case/whitespace variants such as "Income"
+		// instead of normalizing them.
+		StrictSaleTypes bool `yaml:"strict_sale_types" toml:"strict_sale_types"`
+		// EnvelopeResponses makes list endpoints answer with the v2 envelope
+		// by default. Clients can always pick a format via the Accept header.
+		EnvelopeResponses bool `yaml:"envelope_responses" toml:"envelope_responses"`
+		// MaxBodyBytes caps the size of any other request body; larger
+		// requests get a 413. MaxImportBytes caps a CSV import upload.
+		MaxBodyBytes   int64 `yaml:"max_body_bytes" toml:"max_body_bytes" env-default:"1048576"`
+		MaxImportBytes int64 `yaml:"max_import_bytes" toml:"max_import_bytes" env-default:"10485760"`
+		// MaxAttachmentBytes caps an attachment upload.
+		MaxAttachmentBytes int64 `yaml:"max_attachment_bytes" toml:"max_attachment_bytes" env-default:"5242880"`
+		// Compression gzips responses for clients that accept it once they
+		// reach CompressionMinBytes; smaller ones aren't worth the overhead.
+		Compression         bool  `yaml:"compression" toml:"compression" env:"SERVER_COMPRESSION"`
+		CompressionMinBytes int64 `yaml:"compression_min_bytes" toml:"compression_min_bytes" env-default:"1024"`
+		// AllowedOrigins enables CORS for the listed origins ("*" for any).
+		// Empty keeps the API same-origin only. AllowedMethods and
+		// AllowedHeaders default to the methods and headers the API uses.
+		AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"`
+		AllowedMethods []string `yaml:"allowed_methods" toml:"allowed_methods"`
+		AllowedHeaders []string `yaml:"allowed_headers" toml:"allowed_headers"`
+		// RateLimit is the sustained requests per second allowed per client
+		// IP on /api, with bursts up to RateBurst. Zero disables limiting.
+		RateLimit float64 `yaml:"rate_limit" toml:"rate_limit" env-default:"0"`
+		RateBurst int     `yaml:"rate_burst" toml:"rate_burst" env-default:"20"`
+		// TrustedProxies lists the IPs and CIDRs of reverse proxies whose
+		// X-Forwarded-For header is believed when working out a client's IP.
+		// Empty trusts none, so clients are told apart by their own address.
+		TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+		// IdempotencyWindow is how long an Idempotency-Key on POST /api/items
+		// is remembered; a repeat within it returns the original sale.
+		IdempotencyWindow time.Duration `yaml:"idempotency_window" toml:"idempotency_window" env-default:"24h"`
+		// ReadTimeout, WriteTimeout and IdleTimeout bound how long a client
+		// may take to send a request, to receive the response, and to keep an
+		// idle keep-alive connection open. Exports and attachment downloads
+		// are not bound by WriteTimeout, so it doesn't cap their size.
+		ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout" env-default:"15s"`
+		WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout" env-default:"15s"`
+		IdleTimeout  time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env-default:"60s"`
+		// AllowDangerousOperations enables endpoints that destroy data in
+		// bulk, such as DELETE /api/items/all. Keep it off in production.
+		AllowDangerousOperations bool `yaml:"allow_dangerous_operations" toml:"allow_dangerous_operations" env:"ALLOW_DANGEROUS_OPERATIONS"`
+		// RequestTimeout bounds how long a request may run; context-aware
+		// work is cancelled and the client gets a 503 when it runs over. Keep
+		// it below WriteTimeout so the 503 can still be written. Exports and
+		// attachment downloads are exempt. Zero disables it.
+		RequestTimeout time.Duration `yaml:"request_timeout" toml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT" env-default:"10s"`
+		// ShutdownTimeout is how long a shutdown waits for in-flight
+		// requests before closing their connections. Zero waits for them
+		// indefinitely.
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" env-default:"10s"`
+		// TLSCertFile and TLSKeyFile, when both set, make the server speak
+		// HTTPS with that PEM certificate and key instead of plain HTTP.
+		TLSCertFile string `yaml:"tls_cert_file" toml:"tls_cert_file" env:"SERVER_TLS_CERT_FILE"`
+		TLSKeyFile  string `yaml:"tls_key_file" toml:"tls_key_file" env:"SERVER_TLS_KEY_FILE"`
+	} `yaml:"server" toml:"server"`
+	Auth struct {
+		// JWTSecret signs API bearer tokens. Empty leaves the API open.
+		JWTSecret string `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET"`
+		// Username and Password are the credentials POST /api/login accepts.
+		Username string        `yaml:"username" toml:"username" env:"AUTH_USERNAME"`
+		Password string        `yaml:"password" toml:"password" env:"AUTH_PASSWORD"`
+		TokenTTL time.Duration `yaml:"token_ttl" toml:"token_ttl" env-default:"24h"`
+	} `yaml:"auth" toml:"auth"`
+	Log struct {
+		// Level is one of debug, info, warn or error.
+		Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" env-default:"info"`
+		// Format is "json" for one JSON object per line, or "text".
+		Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" env-default:"json"`
+	} `yaml:"log" toml:"log"`
+	Sales struct {
+		// DefaultCategory is stored when a sale arrives without a category.
+		// Leave empty to make the category required.
+		DefaultCategory string `yaml:"default_category" toml:"default_category" env-default:"Uncategorized"`
+		// DuplicateWindow is how close in date a new sale may be to one with
+		// the same type, amount and category before it is rejected as a
+		// likely duplicate. Zero disables the check.
+		DuplicateWindow time.Duration `yaml:"duplicate_window" toml:"duplicate_window" env-default:"10m"`
+	} `yaml:"sales" toml:"sales"`
+	Recurring struct {
+		// PollInterval is how often due recurring rules are turned into
+		// sales. Zero disables materialization.
+		PollInterval time.Duration `yaml:"poll_interval" toml:"poll_interval" env-default:"1m"`
+	} `yaml:"recurring" toml:"recurring"`
 	Database struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		User     string `yaml:"user"`
-		Password string `yaml:"password"`
-		Name     string `yaml:"name"`
-	} `yaml:"database"`
+		Host     string `yaml:"host" toml:"host" env:"DB_HOST"`
+		Port     string `yaml:"port" toml:"port" env:"DB_PORT"`
+		User     string `yaml:"user" toml:"user" env:"DB_USER"`
+		Password string `yaml:"password" toml:"password" env:"DB_PASSWORD"`
+		Name     string `yaml:"name" toml:"name" env:"DB_NAME"`
+		// Pool sizing; zero values keep the pgxpool defaults.
+		MaxConns        int32         `yaml:"max_conns" toml:"max_conns" env-default:"10"`
+		MinConns        int32         `yaml:"min_conns" toml:"min_conns" env-default:"0"`
+		MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" toml:"max_conn_lifetime" env-default:"1h"`
+		// ConnectAttempts bounds how often startup pings the database before
+		// giving up, waiting ConnectRetryDelay after the first failure and
+		// doubling the wait after each further one.
+		ConnectAttempts   int           `yaml:"connect_attempts" toml:"connect_attempts" env-default:"5"`
+		ConnectRetryDelay time.Duration `yaml:"connect_retry_delay" toml:"connect_retry_delay" env-default:"1s"`
+		// MigrationsPath is the directory holding the migration files,
+		// relative to the working directory unless absolute.
+		MigrationsPath string `yaml:"migrations_path" toml:"migrations_path" env:"DB_MIGRATIONS_PATH" env-default:"migrations"`
+		// SkipMigrations leaves schema changes to an out-of-band process; the
+		// server then only checks that a clean schema version is recorded.
+		SkipMigrations bool `yaml:"skip_migrations" toml:"skip_migrations" env:"DB_SKIP_MIGRATIONS"`
+	} `yaml:"database" toml:"database"`
+	Analytics struct {
+		// Timezone is the IANA zone used for calendar-based analytics such as
+		// "this month", and for requests that don't pass ?tz. Empty means UTC.
+		Timezone string `yaml:"timezone" toml:"timezone"`
+		// MinSampleSize is the transaction count below which analytics are
+		// flagged as unreliable.
+		MinSampleSize int `yaml:"min_sample_size" toml:"min_sample_size" env-default:"30"`
+		// MaxConcurrent caps in-flight analytics queries; requests beyond it
+		// get a 503. Zero means unlimited.
+		MaxConcurrent int `yaml:"max_concurrent" toml:"max_concurrent" env-default:"4"`
+		// DefaultRange fills in a from or to left off an analytics request:
+		// "current_month" for the calendar month so far and beyond, or "Nd"
+		// (e.g. "30d") for the last N days including today. Empty makes both
+		// parameters required.
+		DefaultRange string `yaml:"default_range" toml:"default_range" env:"ANALYTICS_DEFAULT_RANGE" env-default:"current_month"`
+		// CacheTTL is how long summary analytics results are reused. Any
+		// sale change clears the cache. Zero disables caching.
+		CacheTTL time.Duration `yaml:"cache_ttl" toml:"cache_ttl" env-default:"30s"`
+	} `yaml:"analytics" toml:"analytics"`
+	Webhooks struct {
+		// URLs receive a POST for every sale change. Empty disables webhooks.
+		URLs []string `yaml:"urls" toml:"urls"`
+		// MaxAttempts bounds delivery attempts before a delivery is
+		// dead-lettered; RetryDelay is the initial backoff between them.
+		MaxAttempts int           `yaml:"max_attempts" toml:"max_attempts" env-default:"5"`
+		RetryDelay  time.Duration `yaml:"retry_delay" toml:"retry_delay" env-default:"1s"`
+		Timeout     time.Duration `yaml:"timeout" toml:"timeout" env-default:"10s"`
+	} `yaml:"webhooks" toml:"webhooks"`
+}
+
+// Validate checks that the settings needed to start are present and well
+// formed, reporting every problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+	required := []struct {
+		name, value string
+	}{
+		{"server.port", c.Server.Port},
+		{"database.host", c.Database.Host},
+		{"database.port", c.Database.Port},
+		{"database.user", c.Database.User},
+		{"database.name", c.Database.Name},
+	}
+	for _, field := range required {
+		if field.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", field.name))
+		}
+	}
+
+	if c.Server.Port != "" {
+		if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("server.port %q is not a valid port number", c.Server.Port))
+		}
+	}
+
+	if c.Server.BasePath != "" && !strings.HasPrefix(c.Server.BasePath, "/") {
+		errs = append(errs, fmt.Errorf("server.base_path %q must start with /", c.Server.BasePath))
+	}
+
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies entry %q is not an IP or CIDR", proxy))
+		}
+	}
+
+	switch c.Server.GinMode {
+	case "", "release", "debug", "test":
+	default:
+		errs = append(errs, fmt.Errorf("server.gin_mode %q must be release, debug or test", c.Server.GinMode))
+	}
+
+	if spec := c.Analytics.DefaultRange; spec != "" && spec != "current_month" {
+		days, found := strings.CutSuffix(spec, "d")
+		if n, err := strconv.Atoi(days); !found || err != nil || n < 1 {
+			errs = append(errs, fmt.Errorf(`analytics.default_range %q must be "current_month" or a number of days such as "30d"`, spec))
+		}
+	}
+
+	if c.Log.Level != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+			errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+		}
+	}
+	if format := strings.ToLower(c.Log.Format); format != "" && format != "json" && format != "text" {
+		errs = append(errs, fmt.Errorf("log.format %q must be json or text", c.Log.Format))
+	}
+
+	if cert, key := c.Server.TLSCertFile, c.Server.TLSKeyFile; cert != "" || key != "" {
+		tlsFiles := []struct {
+			name, path string
+		}{
+			{"server.tls_cert_file", cert},
+			{"server.tls_key_file", key},
+		}
+		for _, file := range tlsFiles {
+			if file.path == "" {
+				errs = append(errs, fmt.Errorf("%s is required when TLS is configured", file.name))
+			} else if _, err := os.Stat(file.path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file.name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
 }
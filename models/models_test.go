@@ -0,0 +1,141 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSale_SignedAmount(t *testing.T) {
+	amount := decimal.RequireFromString("12.50")
+
+	income := Sale{Type: "income", Amount: amount}
+	assert.Equal(t, "12.5", income.SignedAmount().String())
+
+	expense := Sale{Type: "expense", Amount: amount}
+	assert.Equal(t, "-12.5", expense.SignedAmount().String())
+
+	// The stored amount itself stays positive.
+	assert.True(t, expense.Amount.IsPositive())
+
+	net := income.SignedAmount().Add(expense.SignedAmount()).Add(Sale{Type: "expense", Amount: decimal.NewFromInt(5)}.SignedAmount())
+	assert.Equal(t, "-5", net.String())
+}
+
+func TestIncomeRatios(t *testing.T) {
+	savings, ratio := IncomeRatios(decimal.RequireFromString("4000"), decimal.RequireFromString("3000"))
+	require.NotNil(t, savings)
+	assert.Equal(t, "25", savings.String())
+	assert.Equal(t, "75", ratio.String())
+
+	// Spending more than earned gives a negative savings rate.
+	savings, ratio = IncomeRatios(decimal.RequireFromString("300"), decimal.RequireFromString("400"))
+	assert.Equal(t, "-33.33", savings.String())
+	assert.Equal(t, "133.33", ratio.String())
+
+	savings, ratio = IncomeRatios(decimal.Zero, decimal.RequireFromString("50"))
+	assert.Nil(t, savings, "no income, no ratios")
+	assert.Nil(t, ratio)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() *Config {
+		cfg := &Config{}
+		cfg.Server.Port = "8080"
+		cfg.Database.Host = "db"
+		cfg.Database.Port = "5432"
+		cfg.Database.User = "postgres"
+		cfg.Database.Name = "salesdb"
+		return cfg
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, valid().Validate())
+	})
+
+	t.Run("lists every missing field", func(t *testing.T) {
+		err := (&Config{}).Validate()
+		assert.Error(t, err)
+		for _, field := range []string{"server.port", "database.host", "database.port", "database.user", "database.name"} {
+			assert.Contains(t, err.Error(), field)
+		}
+	})
+
+	t.Run("non-numeric server port", func(t *testing.T) {
+		cfg := valid()
+		cfg.Server.Port = "http"
+		assert.ErrorContains(t, cfg.Validate(), `server.port "http"`)
+	})
+
+	t.Run("tls files", func(t *testing.T) {
+		dir := t.TempDir()
+		cert := filepath.Join(dir, "cert.pem")
+		require.NoError(t, os.WriteFile(cert, []byte("cert"), 0o600))
+
+		cfg := valid()
+		cfg.Server.TLSCertFile = cert
+		assert.ErrorContains(t, cfg.Validate(), "server.tls_key_file is required")
+
+		cfg.Server.TLSKeyFile = filepath.Join(dir, "missing.pem")
+		assert.ErrorContains(t, cfg.Validate(), "server.tls_key_file")
+
+		cfg.Server.TLSKeyFile = cert
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("relative base path", func(t *testing.T) {
+		cfg := valid()
+		cfg.Server.BasePath = "finance"
+		assert.ErrorContains(t, cfg.Validate(), `server.base_path "finance"`)
+
+		cfg.Server.BasePath = "/finance"
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("default analytics range", func(t *testing.T) {
+		cfg := valid()
+		for _, spec := range []string{"current_month", "30d", "7d"} {
+			cfg.Analytics.DefaultRange = spec
+			assert.NoError(t, cfg.Validate(), spec)
+		}
+		for _, spec := range []string{"month", "0d", "-3d", "30"} {
+			cfg.Analytics.DefaultRange = spec
+			assert.ErrorContains(t, cfg.Validate(), "analytics.default_range", spec)
+		}
+	})
+
+	t.Run("gin mode", func(t *testing.T) {
+		cfg := valid()
+		for _, mode := range []string{"", "release", "debug", "test"} {
+			cfg.Server.GinMode = mode
+			assert.NoError(t, cfg.Validate(), mode)
+		}
+		cfg.Server.GinMode = "production"
+		assert.ErrorContains(t, cfg.Validate(), `server.gin_mode "production"`)
+	})
+
+	t.Run("trusted proxies", func(t *testing.T) {
+		cfg := valid()
+		cfg.Server.TrustedProxies = []string{"10.0.0.1", "172.16.0.0/12", "::1"}
+		assert.NoError(t, cfg.Validate())
+		cfg.Server.TrustedProxies = []string{"proxy.local"}
+		assert.ErrorContains(t, cfg.Validate(), `server.trusted_proxies entry "proxy.local"`)
+	})
+
+	t.Run("log settings", func(t *testing.T) {
+		cfg := valid()
+		cfg.Log.Level = "verbose"
+		cfg.Log.Format = "xml"
+		err := cfg.Validate()
+		assert.ErrorContains(t, err, `log.level "verbose"`)
+		assert.ErrorContains(t, err, `log.format "xml"`)
+
+		cfg.Log.Level = "WARN"
+		cfg.Log.Format = "text"
+		assert.NoError(t, cfg.Validate())
+	})
+}
@@ -1,3 +1,6 @@
+// The UI is served from {base}/web, so the API lives at {base}/api.
+const API_BASE = window.location.pathname.replace(/\/web(\/.*)?$/, '') + '/api';
+
 class SalesTracker {
     constructor() {
         this.init();
@@ -33,7 +36,7 @@ class SalesTracker {
         };
 
         try {
-            const response = await fetch('/api/items', {
+            const response = await fetch(`${API_BASE}/items`, {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json'
@@ -54,7 +57,7 @@ class SalesTracker {
 
     async loadSales() {
         try {
-            const response = await fetch('/api/items');
+            const response = await fetch(`${API_BASE}/items`);
             const sales = await response.json();
             this.renderSales(sales);
         } catch (error) {
@@ -103,7 +106,7 @@ class SalesTracker {
         try {
             const fromDate = new Date(from).toISOString();
             const toDate = new Date(to).toISOString();
-            const response = await fetch(`/api/analytics?from=${encodeURIComponent(fromDate)}&to=${encodeURIComponent(toDate)}`);
+            const response = await fetch(`${API_BASE}/analytics?from=${encodeURIComponent(fromDate)}&to=${encodeURIComponent(toDate)}`);
             const analytics = await response.json();
             this.renderAnalytics(analytics);
         } catch (error) {
@@ -112,11 +115,14 @@ class SalesTracker {
     }
 
     renderAnalytics(analytics) {
-        document.getElementById('totalSales').textContent = analytics.sum.toFixed(2);
-        document.getElementById('average').textContent = analytics.average.toFixed(2);
+        document.getElementById('totalSales').textContent = Number(analytics.sum).toFixed(2);
+        document.getElementById('average').textContent = Number(analytics.average).toFixed(2);
         document.getElementById('count').textContent = analytics.count;
-        document.getElementById('median').textContent = analytics.median.toFixed(2);
-        document.getElementById('percentile90').textContent = analytics.percentile90.toFixed(2);
+        document.getElementById('median').textContent = Number(analytics.median).toFixed(2);
+        document.getElementById('percentile90').textContent = Number(analytics.percentile90).toFixed(2);
+        document.getElementById('incomeSum').textContent = Number(analytics.income_sum).toFixed(2);
+        document.getElementById('expenseSum').textContent = Number(analytics.expense_sum).toFixed(2);
+        document.getElementById('net').textContent = Number(analytics.net).toFixed(2);
     }
 
     resetForm() {
@@ -131,7 +137,7 @@ class SalesTracker {
     async deleteSale(id) {
         if (confirm('Are you sure you want to delete this sale?')) {
             try {
-                const response = await fetch(`/api/items/${id}`, {
+                const response = await fetch(`${API_BASE}/items/${id}`, {
                     method: 'DELETE'
                 });
 